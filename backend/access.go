@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+)
+
+// pod manager 는 러너 pod 을 만들 때 IRIS_RUNNER_TOKEN 을 넣고, 러너로 보내는 모든 요청에
+// 같은 값을 runnerTokenHeader 로 붙인다. 토큰이 설정된 러너는 이 헤더가 없는 요청을 거절해서
+// 클러스터 안의 다른 pod 이 러너에 직접 연결하지 못하게 한다.
+// 토큰이 없으면(docker-compose 로 직접 띄운 경우) 모든 요청을 받는다.
+const runnerTokenHeader = "X-Iris-Runner-Token"
+
+var runnerToken = os.Getenv("IRIS_RUNNER_TOKEN")
+
+// fromManager 는 kubelet 프로브처럼 토큰 없이 와야 하는 /healthz 를 뺀 나머지 핸들러를 감싼다.
+func fromManager(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if runnerToken == "" {
+			handler(w, r)
+			return
+		}
+		token := r.Header.Get(runnerTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(runnerToken)) != 1 {
+			log.Printf("rejected %s %s from %s: missing or invalid runner token", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromManager(t *testing.T) {
+	handler := fromManager(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(token string) int {
+		r := httptest.NewRequest("GET", "/capabilities", nil)
+		if token != "" {
+			r.Header.Set(runnerTokenHeader, token)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		return recorder.Code
+	}
+
+	original := runnerToken
+	defer func() { runnerToken = original }()
+
+	// 토큰을 설정하지 않은 러너는 모든 요청을 받는다.
+	runnerToken = ""
+	if code := request(""); code != http.StatusNoContent {
+		t.Errorf("without a runner token: %d", code)
+	}
+
+	runnerToken = "secret"
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{"secret", http.StatusNoContent},
+		{"", http.StatusForbidden},
+		{"wrong", http.StatusForbidden},
+		{"secret2", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code := request(tt.token); code != tt.want {
+			t.Errorf("token %q: status = %d, want %d", tt.token, code, tt.want)
+		}
+	}
+}
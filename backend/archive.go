@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	archiveMaxCompressed   = 10 * 1024 * 1024
+	archiveMaxUncompressed = 50 * 1024 * 1024
+	archiveMaxFileSize     = 10 * 1024 * 1024
+	archiveMaxFiles        = 1000
+)
+
+const (
+	ArchiveErrInvalid       = "invalid_archive"
+	ArchiveErrTooLarge      = "too_large"
+	ArchiveErrFileTooLarge  = "file_too_large"
+	ArchiveErrTooManyFiles  = "too_many_files"
+	ArchiveErrPathTraversal = "path_traversal"
+	ArchiveErrLink          = "link_not_allowed"
+	ArchiveErrUnsupported   = "unsupported_entry"
+)
+
+type ArchiveError struct {
+	Reason string
+	Entry  string
+	Err    error
+}
+
+func (e *ArchiveError) Error() string {
+	if e.Entry != "" {
+		return fmt.Sprintf("%s: %s: %v", e.Reason, e.Entry, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// decodeArchive 는 code 메시지의 base64 archive 를 디코드한다.
+func decodeArchive(encoded string) ([]byte, error) {
+	if base64.StdEncoding.DecodedLen(len(encoded)) > archiveMaxCompressed+3 {
+		return nil, &ArchiveError{Reason: ArchiveErrTooLarge, Err: fmt.Errorf("archive exceeds %d bytes", archiveMaxCompressed)}
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, &ArchiveError{Reason: ArchiveErrInvalid, Err: fmt.Errorf("invalid base64: %w", err)}
+	}
+	return data, nil
+}
+
+// extractArchive 는 zip 또는 tar.gz 를 workspace 에 푼다. 형식은 매직 넘버로 판별한다.
+func extractArchive(box *Box, data []byte) error {
+	if len(data) > archiveMaxCompressed {
+		return &ArchiveError{Reason: ArchiveErrTooLarge, Err: fmt.Errorf("archive exceeds %d bytes", archiveMaxCompressed)}
+	}
+
+	x := &archiveExtractor{box: box}
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return x.extractZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return x.extractTarGz(data)
+	}
+	return &ArchiveError{Reason: ArchiveErrInvalid, Err: errors.New("unknown archive format, expected zip or tar.gz")}
+}
+
+type archiveExtractor struct {
+	box   *Box
+	files int
+	total int64
+}
+
+func (x *archiveExtractor) extractZip(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Err: err}
+	}
+
+	for _, file := range reader.File {
+		mode := file.Mode()
+		switch {
+		case mode&os.ModeSymlink != 0:
+			return &ArchiveError{Reason: ArchiveErrLink, Entry: file.Name, Err: errors.New("symlinks are not allowed")}
+		case mode.IsDir():
+			if err := x.mkdir(file.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := file.Open()
+			if err != nil {
+				return &ArchiveError{Reason: ArchiveErrInvalid, Entry: file.Name, Err: err}
+			}
+			err = x.writeFile(file.Name, rc, mode)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return &ArchiveError{Reason: ArchiveErrUnsupported, Entry: file.Name, Err: fmt.Errorf("unsupported file mode %v", mode)}
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) extractTarGz(data []byte) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Err: err}
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return &ArchiveError{Reason: ArchiveErrInvalid, Err: err}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := x.mkdir(header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(header.Name, reader, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink, tar.TypeLink:
+			return &ArchiveError{Reason: ArchiveErrLink, Entry: header.Name, Err: errors.New("links are not allowed")}
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return &ArchiveError{Reason: ArchiveErrUnsupported, Entry: header.Name, Err: fmt.Errorf("unsupported entry type %q", header.Typeflag)}
+		}
+	}
+}
+
+func (x *archiveExtractor) target(name string) (string, error) {
+	if strings.Contains(name, "\\") || strings.ContainsRune(name, 0) {
+		return "", &ArchiveError{Reason: ArchiveErrPathTraversal, Entry: name, Err: errors.New("invalid characters in path")}
+	}
+	target, err := x.box.workspacePath(strings.TrimPrefix(name, "./"))
+	if err != nil {
+		return "", &ArchiveError{Reason: ArchiveErrPathTraversal, Entry: name, Err: errors.New("path escapes the workspace")}
+	}
+	// 앞서 만든 디렉터리가 심볼릭 링크가 아닌지 확인해서 workspace 밖으로 나가지 않게 한다.
+	for dir := filepath.Dir(target); dir != x.box.Workspace; dir = filepath.Dir(dir) {
+		if info, err := os.Lstat(dir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", &ArchiveError{Reason: ArchiveErrLink, Entry: name, Err: errors.New("path goes through a symlink")}
+		}
+	}
+	return target, nil
+}
+
+func (x *archiveExtractor) mkdir(name string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := x.box.mkdirAll(target); err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Entry: name, Err: err}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) writeFile(name string, r io.Reader, mode os.FileMode) error {
+	x.files++
+	if x.files > archiveMaxFiles {
+		return &ArchiveError{Reason: ArchiveErrTooManyFiles, Entry: name, Err: fmt.Errorf("archive has more than %d files", archiveMaxFiles)}
+	}
+
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if err := x.box.mkdirAll(filepath.Dir(target)); err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Entry: name, Err: err}
+	}
+
+	perm := os.FileMode(0o644)
+	if mode&0o111 != 0 {
+		perm = 0o755
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Entry: name, Err: err}
+	}
+	defer f.Close()
+
+	// 헤더의 크기는 믿지 않고 실제로 읽은 양으로 제한한다.
+	n, err := io.Copy(f, io.LimitReader(r, archiveMaxFileSize+1))
+	if err != nil {
+		return &ArchiveError{Reason: ArchiveErrInvalid, Entry: name, Err: err}
+	}
+	if n > archiveMaxFileSize {
+		return &ArchiveError{Reason: ArchiveErrFileTooLarge, Entry: name, Err: fmt.Errorf("file exceeds %d bytes", archiveMaxFileSize)}
+	}
+	x.total += n
+	if x.total > archiveMaxUncompressed {
+		return &ArchiveError{Reason: ArchiveErrTooLarge, Entry: name, Err: fmt.Errorf("extracted size exceeds %d bytes", archiveMaxUncompressed)}
+	}
+	return nil
+}
+
+func writeArchiveError(ctx *ConnectionContext, err error) {
+	event := map[string]interface{}{
+		"type":  "archive_error",
+		"error": err.Error(),
+	}
+	var archiveErr *ArchiveError
+	if errors.As(err, &archiveErr) {
+		event["reason"] = archiveErr.Reason
+		event["entry"] = archiveErr.Entry
+	}
+	ctx.write(event)
+}
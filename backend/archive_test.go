@@ -0,0 +1,235 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+type archiveEntry struct {
+	name     string
+	data     []byte
+	size     int
+	mode     os.FileMode
+	typeflag byte
+	linkname string
+}
+
+// content 는 size 가 있으면 그 크기의 0 바이트를 돌려준다. 큰 항목도 압축하면 작다.
+func (e archiveEntry) content() []byte {
+	if e.size > 0 {
+		return make([]byte, e.size)
+	}
+	return e.data
+}
+
+func buildZip(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		mode := entry.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		header.SetMode(mode)
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(entry.content()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for _, entry := range entries {
+		data := entry.content()
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Typeflag: entry.typeflag, Linkname: entry.linkname}
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(data))
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newTestWorkspace 는 임시 디렉터리 안에 workspace 를 둔 box 를 만든다. workspace 밖(root)에 생긴 파일로 탈출 여부를 본다.
+func newTestWorkspace(t *testing.T) (*Box, string) {
+	t.Helper()
+	root := t.TempDir()
+	box := &Box{ID: sessionBoxBase, Workspace: filepath.Join(root, "workspace")}
+	if err := os.Mkdir(box.Workspace, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return box, root
+}
+
+func manyFiles(n int) []archiveEntry {
+	entries := make([]archiveEntry, n)
+	for i := range entries {
+		entries[i] = archiveEntry{name: fmt.Sprintf("f%d.txt", i), data: []byte("x")}
+	}
+	return entries
+}
+
+func TestExtractArchiveRejects(t *testing.T) {
+	nineMB := 9 * 1024 * 1024
+	tooMuch := make([]archiveEntry, archiveMaxUncompressed/nineMB+1)
+	for i := range tooMuch {
+		tooMuch[i] = archiveEntry{name: fmt.Sprintf("big%d.bin", i), size: nineMB}
+	}
+
+	tests := []struct {
+		name    string
+		format  string
+		entries []archiveEntry
+		reason  string
+	}{
+		{"zip parent traversal", "zip", []archiveEntry{{name: "../escape.txt", data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"zip nested traversal", "zip", []archiveEntry{{name: "src/../../escape.txt", data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"zip absolute path", "zip", []archiveEntry{{name: "/escape.txt", data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"zip backslash path", "zip", []archiveEntry{{name: `..\escape.txt`, data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"zip symlink", "zip", []archiveEntry{{name: "link", data: []byte("/etc/passwd"), mode: os.ModeSymlink | 0o777}}, ArchiveErrLink},
+		{"zip file too large", "zip", []archiveEntry{{name: "big.bin", size: archiveMaxFileSize + 1}}, ArchiveErrFileTooLarge},
+		{"zip total too large", "zip", tooMuch, ArchiveErrTooLarge},
+		{"zip too many files", "zip", manyFiles(archiveMaxFiles + 1), ArchiveErrTooManyFiles},
+		{"tar parent traversal", "tar.gz", []archiveEntry{{name: "../escape.txt", data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"tar absolute path", "tar.gz", []archiveEntry{{name: "/escape.txt", data: []byte("x")}}, ArchiveErrPathTraversal},
+		{"tar symlink", "tar.gz", []archiveEntry{{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}}, ArchiveErrLink},
+		{"tar symlink then write through it", "tar.gz", []archiveEntry{
+			{name: "dir", typeflag: tar.TypeSymlink, linkname: ".."},
+			{name: "dir/escape.txt", data: []byte("x")},
+		}, ArchiveErrLink},
+		{"tar hardlink", "tar.gz", []archiveEntry{
+			{name: "a.txt", data: []byte("x")},
+			{name: "b.txt", typeflag: tar.TypeLink, linkname: "a.txt"},
+		}, ArchiveErrLink},
+		{"tar hardlink outside", "tar.gz", []archiveEntry{{name: "passwd", typeflag: tar.TypeLink, linkname: "/etc/passwd"}}, ArchiveErrLink},
+		{"tar device", "tar.gz", []archiveEntry{{name: "null", typeflag: tar.TypeChar}}, ArchiveErrUnsupported},
+		{"tar file too large", "tar.gz", []archiveEntry{{name: "big.bin", size: archiveMaxFileSize + 1}}, ArchiveErrFileTooLarge},
+		{"tar total too large", "tar.gz", tooMuch, ArchiveErrTooLarge},
+		{"tar too many files", "tar.gz", manyFiles(archiveMaxFiles + 1), ArchiveErrTooManyFiles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data []byte
+			if tt.format == "zip" {
+				data = buildZip(t, tt.entries)
+			} else {
+				data = buildTarGz(t, tt.entries)
+			}
+			box, root := newTestWorkspace(t)
+
+			err := extractArchive(box, data)
+			var archiveErr *ArchiveError
+			if !errors.As(err, &archiveErr) {
+				t.Fatalf("extractArchive() error = %v, want *ArchiveError", err)
+			}
+			if archiveErr.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q (%v)", archiveErr.Reason, tt.reason, err)
+			}
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("files written outside the workspace: %v", entries)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsOversizedInput(t *testing.T) {
+	box, _ := newTestWorkspace(t)
+	data := append([]byte("PK\x03\x04"), make([]byte, archiveMaxCompressed)...)
+
+	var archiveErr *ArchiveError
+	if err := extractArchive(box, data); !errors.As(err, &archiveErr) || archiveErr.Reason != ArchiveErrTooLarge {
+		t.Fatalf("extractArchive() error = %v, want %s", err, ArchiveErrTooLarge)
+	}
+}
+
+func TestExtractArchiveRejectsUnknownFormat(t *testing.T) {
+	box, _ := newTestWorkspace(t)
+
+	var archiveErr *ArchiveError
+	if err := extractArchive(box, []byte("not an archive")); !errors.As(err, &archiveErr) || archiveErr.Reason != ArchiveErrInvalid {
+		t.Fatalf("extractArchive() error = %v, want %s", err, ArchiveErrInvalid)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	entries := []archiveEntry{
+		{name: "main.c", data: []byte("int main(void) { return 0; }\n")},
+		{name: "./src/util.c", data: []byte("int util;\n")},
+		{name: "run.sh", data: []byte("#!/bin/sh\n"), mode: 0o755},
+	}
+	for _, format := range []string{"zip", "tar.gz"} {
+		t.Run(format, func(t *testing.T) {
+			var data []byte
+			if format == "zip" {
+				data = buildZip(t, entries)
+			} else {
+				data = buildTarGz(t, entries)
+			}
+			box, _ := newTestWorkspace(t)
+			if err := extractArchive(box, data); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			for _, entry := range entries {
+				got, err := os.ReadFile(filepath.Join(box.Workspace, entry.name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, entry.data) {
+					t.Errorf("%s = %q, want %q", entry.name, got, entry.data)
+				}
+			}
+			// 샌드박스 사용자가 풀린 디렉터리에 파일을 만들 수 있어야 한다.
+			if os.Geteuid() == 0 {
+				info, err := os.Stat(filepath.Join(box.Workspace, "src"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if uid := info.Sys().(*syscall.Stat_t).Uid; uid != uint32(isolateFirstUID+box.ID) {
+					t.Errorf("src owner = %d, want %d", uid, isolateFirstUID+box.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	errNotRegularFile = errors.New("not a regular file")
+	errFileTooLarge   = errors.New("file too large")
+)
+
+// openBeneath 는 root 아래의 호스트 경로를 연다. 러너는 root 로 실행되고 사용자 프로그램은 자기가 쓸 수 있는
+// 디렉터리의 어느 경로든 symlink 로 바꿔 놓을 수 있으므로, openat2 로 root 밖으로 나가거나 symlink 를 지나는
+// 경로는 중간 디렉터리까지 모두 거절한다.
+func openBeneath(root, path string, flag int, perm os.FileMode) (*os.File, error) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil, fmt.Errorf("%s is outside %s", path, root)
+	}
+	dir, err := unix.Open(root, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: root, Err: err}
+	}
+	defer unix.Close(dir)
+
+	fd, err := unix.Openat2(dir, rel, &unix.OpenHow{
+		Flags:   uint64(flag | unix.O_CLOEXEC),
+		Mode:    uint64(perm.Perm()),
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
+
+// readFileBeneath 는 root 아래의 일반 파일을 limit 바이트까지 읽는다. FIFO 를 열다가 멈추지 않도록
+// O_NONBLOCK 으로 연다.
+func readFileBeneath(root, path string, limit int64) ([]byte, error) {
+	f, err := openBeneath(root, path, unix.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, unix.ELOOP) {
+			return nil, errNotRegularFile
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errNotRegularFile
+	}
+	if info.Size() > limit {
+		return nil, errFileTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errFileTooLarge
+	}
+	return data, nil
+}
+
+// writeFileBeneath 는 root 아래에 파일을 쓴다. 마지막 경로가 symlink 여도 따라가지 않는다.
+func writeFileBeneath(root, path string, data []byte, perm os.FileMode) error {
+	f, err := openBeneath(root, path, unix.O_WRONLY|unix.O_CREAT|unix.O_TRUNC|unix.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// removeAllBeneath 는 root 아래의 경로를 지운다. 부모 디렉터리는 openBeneath 로 열어서 그 fd 기준으로 지우고,
+// os.RemoveAll 은 그 아래에서 symlink 를 따라가지 않는다.
+func removeAllBeneath(root, path string) error {
+	parent, err := openBeneath(root, filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer parent.Close()
+	return os.RemoveAll(fmt.Sprintf("/proc/self/fd/%d/%s", parent.Fd(), filepath.Base(path)))
+}
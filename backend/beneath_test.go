@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReadFileBeneath(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "trace.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "big"), make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(root, "dirlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(root, "fifo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readFileBeneath(root, filepath.Join(root, "trace.json"), 10)
+	if err != nil || string(data) != "{}" {
+		t.Fatalf("readFileBeneath = %q, %v", data, err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"symlink", filepath.Join(root, "link"), errNotRegularFile},
+		{"symlinked directory", filepath.Join(root, "dirlink", "secret"), errNotRegularFile},
+		{"fifo", filepath.Join(root, "fifo"), errNotRegularFile},
+		{"too large", filepath.Join(root, "big"), errFileTooLarge},
+		{"missing", filepath.Join(root, "missing"), os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readFileBeneath(root, tt.path, 10); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := readFileBeneath(root, outside, 10); err == nil {
+		t.Fatal("path outside root was read")
+	}
+}
+
+func TestOpenBeneathRejectsSymlinkedParent(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := openBeneath(root, filepath.Join(root, "dir", "new"), unix.O_WRONLY|unix.O_CREAT, 0o644)
+	if !errors.Is(err, unix.ELOOP) {
+		t.Fatalf("err = %v, want ELOOP", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "new")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("file was created outside root: %v", err)
+	}
+}
+
+func TestWriteFileBeneathDoesNotFollowSymlink(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "in.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileBeneath(root, filepath.Join(root, "in.txt"), []byte("input"), 0o644); err == nil {
+		t.Fatal("wrote through a symlink")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "keep" {
+		t.Fatalf("target = %q", data)
+	}
+	if err := writeFileBeneath(root, filepath.Join(root, "out.txt"), []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "out.txt")); string(data) != "ok" {
+		t.Fatalf("out.txt = %q", data)
+	}
+}
+
+func TestRemoveAllBeneath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	kept := filepath.Join(outside, "kept")
+	if err := os.WriteFile(kept, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "dir", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "parent")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeAllBeneath(root, filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "dir")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dir was not removed: %v", err)
+	}
+	if err := removeAllBeneath(root, filepath.Join(root, "parent", "kept")); err == nil {
+		t.Fatal("removed through a symlinked parent")
+	}
+	if err := removeAllBeneath(root, filepath.Join(root, "missing", "file")); err != nil {
+		t.Fatalf("missing parent: %v", err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Fatalf("file outside root was removed: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 러너 pod 하나가 여러 세션을 동시에 받을 수 있도록 연결마다 isolate box 를 풀에서 빌려 준다.
+// box 마다 호스트의 boxWorkspaceRoot/<id> 를 workspace 로 쓰지만, 샌드박스 안과 컴파일 명령에서는
+// 항상 workspaceDir(/code) 로 보인다. 그래서 CompileOptions 의 경로는 box 와 상관없이 /code 기준이고,
+// 러너가 직접 파일을 읽고 쓸 때만 Box.path 로 호스트 경로로 바꾼다.
+const (
+	boxWorkspaceRoot = "/var/lib/iris/boxes"
+	// 0 은 readiness 검사, 1 은 포매터 box 라서 세션 box 는 2 번부터 쓴다.
+	sessionBoxBase = 2
+	maxBoxPoolSize = 16
+)
+
+var errNoFreeBox = errors.New("no free isolate box")
+
+var boxes = newBoxPool(envInt("IRIS_BOX_POOL_SIZE", 1))
+
+type Box struct {
+	ID        int
+	Workspace string
+}
+
+func (b *Box) isolateID() string {
+	return strconv.Itoa(b.ID)
+}
+
+// path 는 샌드박스에서 보이는 /code 아래 경로를 이 box 의 호스트 경로로 바꾼다.
+// workspace 밖의 경로는 그대로 둔다.
+func (b *Box) path(sandboxPath string) string {
+	rel, err := filepath.Rel(workspaceDir, sandboxPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return sandboxPath
+	}
+	return filepath.Join(b.Workspace, rel)
+}
+
+// workspacePath 는 사용자가 준 workspace 상대 경로를 검사해서 이 box 의 호스트 경로로 바꾼다.
+func (b *Box) workspacePath(name string) (string, error) {
+	path, err := workspacePath(name)
+	if err != nil {
+		return "", err
+	}
+	return b.path(path), nil
+}
+
+// 사용자 프로그램이 만든 파일을 돌려줄 수 있도록 workspace 를 쓰기 가능하게 마운트하고 작업 디렉터리로 쓴다.
+func (b *Box) isolateArgs() []string {
+	return []string{
+		"--box-id=" + b.isolateID(),
+		"--dir=" + workspaceDir + "=" + b.Workspace + ":rw",
+		"--dir=/usr/bin",
+		"--chdir=" + workspaceDir,
+	}
+}
+
+// runCommand 는 샌드박스 밖에서 실행하는 컴파일 명령을 별도 mount namespace 에서 실행한다.
+// 이 box 의 workspace 를 /code 에 bind mount 하므로 명령과 컴파일러 출력의 경로가 샌드박스와 같다.
+func (b *Box) runCommand(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("no command to run")
+	}
+	wrapped := []string{
+		"/usr/bin/unshare", "--mount", "--propagation", "private", "--",
+		"/bin/sh", "-c", `mount --bind "$0" ` + workspaceDir + ` && exec "$@"`, b.Workspace,
+	}
+	return runCommand(append(wrapped, args...))
+}
+
+// openFile, readFile, writeFile, removeAll 은 사용자 프로그램이 쓸 수 있는 workspace 의 파일을
+// symlink 를 따라가지 않고 다룬다. (beneath.go)
+func (b *Box) openFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	return openBeneath(b.Workspace, path, flag, perm)
+}
+
+func (b *Box) readFile(path string, limit int64) ([]byte, error) {
+	return readFileBeneath(b.Workspace, path, limit)
+}
+
+func (b *Box) writeFile(path string, data []byte, perm os.FileMode) error {
+	return writeFileBeneath(b.Workspace, path, data, perm)
+}
+
+func (b *Box) removeAll(path string) error {
+	return removeAllBeneath(b.Workspace, path)
+}
+
+// chown 은 러너가 workspace 에 만든 파일이나 디렉터리를 이 box 의 샌드박스 사용자에게 넘긴다.
+// mode 는 umask 에 영향을 받으므로 권한을 넓히는 대신 소유자를 바꾼다.
+func (b *Box) chown(path string) error {
+	return os.Lchown(path, isolateFirstUID+b.ID, isolateFirstGID+b.ID)
+}
+
+// mkdirAll 은 workspace 아래에 dir 까지의 디렉터리를 만들고 샌드박스 사용자에게 넘긴다.
+func (b *Box) mkdirAll(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for ; dir != b.Workspace && strings.HasPrefix(dir, b.Workspace+"/"); dir = filepath.Dir(dir) {
+		if err := b.chown(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Box) resetWorkspace() error {
+	if err := os.MkdirAll(b.Workspace, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(b.Workspace)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		target := filepath.Join(b.Workspace, entry.Name())
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func (b *Box) init() error {
+	if err := b.resetWorkspace(); err != nil {
+		return err
+	}
+	// workspace 는 root 가 만들었으므로 샌드박스 사용자가 테스트 리포트 같은 파일을 쓸 수 있게 넘겨 준다.
+	if err := b.chown(b.Workspace); err != nil {
+		return fmt.Errorf("failed to chown workspace: %w", err)
+	}
+	return runIsolateBoxCommand(b.isolateID(), "--init")
+}
+
+func (b *Box) cleanup() error {
+	isolateErr := runIsolateBoxCommand(b.isolateID(), "--cleanup")
+	if err := b.resetWorkspace(); err != nil {
+		return err
+	}
+	return isolateErr
+}
+
+type boxPool struct {
+	mu    sync.Mutex
+	boxes []*Box
+	free  []*Box
+	// box 가 풀에 돌아올 때마다 닫고 새로 만든다. waitIdle 이 기다린다.
+	returned chan struct{}
+}
+
+func newBoxPool(size int) *boxPool {
+	if size < 1 {
+		size = 1
+	}
+	if size > maxBoxPoolSize {
+		size = maxBoxPoolSize
+	}
+	p := &boxPool{returned: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		id := sessionBoxBase + i
+		box := &Box{ID: id, Workspace: filepath.Join(boxWorkspaceRoot, strconv.Itoa(id))}
+		p.boxes = append(p.boxes, box)
+		p.free = append(p.free, box)
+	}
+	return p
+}
+
+// acquire 는 빈 box 를 꺼내서 초기화한다. 이전 세션이 비정상 종료했을 수 있으므로 먼저 정리한다.
+func (p *boxPool) acquire() (*Box, error) {
+	p.mu.Lock()
+	if len(p.free) == 0 {
+		p.mu.Unlock()
+		return nil, errNoFreeBox
+	}
+	box := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	p.mu.Unlock()
+
+	_ = runIsolateBoxCommand(box.isolateID(), "--cleanup")
+	if err := box.init(); err != nil {
+		p.put(box)
+		return nil, err
+	}
+	return box, nil
+}
+
+// release 는 box 와 workspace 를 정리한 뒤 풀에 돌려준다. 정리에 실패해도 다음 acquire 가 다시 정리한다.
+func (p *boxPool) release(box *Box) error {
+	err := box.cleanup()
+	p.put(box)
+	return err
+}
+
+func (p *boxPool) put(box *Box) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, box)
+	close(p.returned)
+	p.returned = make(chan struct{})
+}
+
+// waitIdle 은 모든 box 가 풀에 돌아올 때까지 timeout 만큼 기다린다.
+func (p *boxPool) waitIdle(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		p.mu.Lock()
+		idle := len(p.free) == len(p.boxes)
+		returned := p.returned
+		p.mu.Unlock()
+		if idle {
+			return true
+		}
+		select {
+		case <-returned:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// takeFree 는 빈 box 를 모두 풀에서 꺼낸다. 돌려줄 때는 put 을 쓴다. busy 는 쓰고 있는 box 수다.
+func (p *boxPool) takeFree() (free []*Box, busy int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	free = p.free
+	p.free = nil
+	return free, len(p.boxes) - len(free)
+}
+
+func (p *boxPool) capacity() (total int, free int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.boxes), len(p.free)
+}
+
+// statusHandler 는 pod manager 가 한 pod 에 몇 세션을 더 보낼 수 있는지 알 수 있게 box 수와 사용 상태를 알려 준다.
+// state 는 세션이 없으면 idle, box 가 모두 쓰이면 busy, 그 사이면 partial 이다.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	total, free := boxes.capacity()
+	state := "partial"
+	switch free {
+	case total:
+		state = "idle"
+	case 0:
+		state = "busy"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"state":    state,
+		"boxes":    total,
+		"free":     free,
+		"sessions": total - free,
+	})
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBoxPath(t *testing.T) {
+	box := &Box{ID: 3, Workspace: "/var/lib/iris/boxes/3"}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{workspaceDir, "/var/lib/iris/boxes/3"},
+		{workspaceDir + "/main.c", "/var/lib/iris/boxes/3/main.c"},
+		{workspaceDir + "/src/../main.c", "/var/lib/iris/boxes/3/main.c"},
+		{"/code2/main.c", "/code2/main.c"},
+		{workspaceDir + "/../etc/passwd", workspaceDir + "/../etc/passwd"},
+		{"/usr/bin/gcc", "/usr/bin/gcc"},
+	}
+	for _, tt := range tests {
+		if got := box.path(tt.path); got != tt.want {
+			t.Errorf("path(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if got, err := box.workspacePath("src/util.c"); err != nil || got != "/var/lib/iris/boxes/3/src/util.c" {
+		t.Errorf("workspacePath(src/util.c) = %q, %v", got, err)
+	}
+	for _, name := range []string{"", ".", "..", "../x", "/etc/passwd"} {
+		if got, err := box.workspacePath(name); err == nil {
+			t.Errorf("workspacePath(%q) = %q, want error", name, got)
+		}
+	}
+}
+
+func TestBoxFilesRejectSymlinks(t *testing.T) {
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(box.Workspace, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(box.Workspace, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := box.writeFile(filepath.Join(box.Workspace, "out.txt"), []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := box.readFile(filepath.Join(box.Workspace, "out.txt"), 16); err != nil || string(data) != "ok" {
+		t.Fatalf("readFile = %q, %v", data, err)
+	}
+	if _, err := box.readFile(filepath.Join(box.Workspace, "out.txt"), 1); !errors.Is(err, errFileTooLarge) {
+		t.Errorf("readFile over the limit error = %v", err)
+	}
+
+	for _, name := range []string{"link", "dir/secret"} {
+		if _, err := box.readFile(filepath.Join(box.Workspace, name), 16); err == nil {
+			t.Errorf("readFile(%s) followed a symlink", name)
+		}
+		if f, err := box.openFile(filepath.Join(box.Workspace, name), unix.O_RDONLY, 0); err == nil {
+			f.Close()
+			t.Errorf("openFile(%s) followed a symlink", name)
+		}
+	}
+	if err := box.writeFile(filepath.Join(box.Workspace, "dir", "secret"), []byte("x"), 0o644); err == nil {
+		t.Error("writeFile followed a symlinked directory")
+	}
+	if err := box.removeAll(filepath.Join(box.Workspace, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outside, "secret")); string(data) != "secret" {
+		t.Errorf("file outside the workspace changed: %q", data)
+	}
+}
+
+func TestBoxMkdirAll(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chown needs root")
+	}
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	if err := box.mkdirAll(filepath.Join(box.Workspace, "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a", "a/b"} {
+		info, err := os.Stat(filepath.Join(box.Workspace, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid := info.Sys().(*syscall.Stat_t).Uid; uid != uint32(isolateFirstUID+box.ID) {
+			t.Errorf("%s owner = %d, want %d", dir, uid, isolateFirstUID+box.ID)
+		}
+	}
+	// workspace 자체의 소유자는 mkdirAll 이 바꾸지 않는다.
+	if info, _ := os.Stat(box.Workspace); info.Sys().(*syscall.Stat_t).Uid != 0 {
+		t.Error("workspace owner changed")
+	}
+}
+
+func TestBoxPool(t *testing.T) {
+	pool := newBoxPool(3)
+	if total, free := pool.capacity(); total != 3 || free != 3 {
+		t.Fatalf("capacity = %d, %d", total, free)
+	}
+	for i, box := range pool.boxes {
+		if box.ID != sessionBoxBase+i {
+			t.Errorf("box %d id = %d", i, box.ID)
+		}
+	}
+	if total, _ := newBoxPool(0).capacity(); total != 1 {
+		t.Errorf("pool of 0 has %d boxes", total)
+	}
+	if total, _ := newBoxPool(100).capacity(); total != maxBoxPoolSize {
+		t.Errorf("pool of 100 has %d boxes", total)
+	}
+
+	taken := pool.free
+	pool.free = nil
+	if _, err := pool.acquire(); !errors.Is(err, errNoFreeBox) {
+		t.Fatalf("acquire() error = %v, want errNoFreeBox", err)
+	}
+	pool.put(taken[0])
+	if _, free := pool.capacity(); free != 1 {
+		t.Errorf("free = %d after put", free)
+	}
+}
+
+func TestBoxPoolTakeFreeAndWaitIdle(t *testing.T) {
+	pool := newBoxPool(3)
+	if !pool.waitIdle(time.Millisecond) {
+		t.Fatal("new pool is not idle")
+	}
+
+	// 세션 하나가 box 를 쓰는 중이다.
+	session := pool.free[len(pool.free)-1]
+	pool.free = pool.free[:len(pool.free)-1]
+
+	free, busy := pool.takeFree()
+	if len(free) != 2 || busy != 1 {
+		t.Fatalf("takeFree() = %d free, %d busy", len(free), busy)
+	}
+	if _, left := pool.capacity(); left != 0 {
+		t.Errorf("free after takeFree = %d", left)
+	}
+	for _, box := range free {
+		pool.put(box)
+	}
+
+	if pool.waitIdle(50 * time.Millisecond) {
+		t.Fatal("waitIdle() returned true while a session holds a box")
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pool.put(session)
+	}()
+	if !pool.waitIdle(5 * time.Second) {
+		t.Fatal("waitIdle() did not see the box return")
+	}
+}
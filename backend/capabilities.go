@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+)
+
+var allModes = []string{ModeRun, ModeTrace, ModeDebug, ModeTest, ModeJudge, ModeRepl}
+
+// LanguageCapability 는 클라이언트가 언어 목록과 사용할 수 있는 기능을 알 수 있도록 보내는 항목이다.
+type LanguageCapability struct {
+	Name     string   `json:"name"`
+	Filename string   `json:"filename,omitempty"`
+	Modes    []string `json:"modes"`
+	Coverage bool     `json:"coverage"`
+	Format   bool     `json:"format"`
+	// 시작 self-test 에서 확인한 툴체인 버전. 깨진 언어는 Disabled 와 이유가 함께 간다.
+	Version        string `json:"version,omitempty"`
+	Disabled       bool   `json:"disabled,omitempty"`
+	DisabledReason string `json:"disabled_reason,omitempty"`
+}
+
+func languageCapabilities() []LanguageCapability {
+	languages := make([]LanguageCapability, 0, len(CompileOptions))
+	for name, option := range CompileOptions {
+		capability := LanguageCapability{
+			Name:     name,
+			Modes:    []string{},
+			Coverage: option.Coverage != nil,
+		}
+		if option.Filename != "" {
+			capability.Filename = filepath.Base(option.Filename)
+		}
+		for _, mode := range allModes {
+			if option.SupportsMode(mode) {
+				capability.Modes = append(capability.Modes, mode)
+			}
+		}
+		capability.Format = len(option.FormatCmd) > 0
+		if result, ok := languageSelfTest(name); ok {
+			capability.Version = result.Version
+		}
+		capability.DisabledReason, capability.Disabled = languageDisabled(name)
+		languages = append(languages, capability)
+	}
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Name < languages[j].Name })
+	return languages
+}
+
+func handleCapabilities(ctx *ConnectionContext) {
+	ctx.write(map[string]interface{}{
+		"type":      "capabilities",
+		"languages": languageCapabilities(),
+	})
+}
+
+func capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"languages": languageCapabilities(),
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLanguageCapabilities(t *testing.T) {
+	languages := languageCapabilities()
+	if len(languages) != len(CompileOptions) {
+		t.Fatalf("got %d languages, want %d", len(languages), len(CompileOptions))
+	}
+	if !sort.SliceIsSorted(languages, func(i, j int) bool { return languages[i].Name < languages[j].Name }) {
+		t.Error("languages are not sorted by name")
+	}
+
+	byName := map[string]LanguageCapability{}
+	for _, language := range languages {
+		byName[language.Name] = language
+	}
+
+	python := byName[PYTHON]
+	if python.Filename != "main.py" || !python.Format || python.Coverage != (CompileOptions[PYTHON].Coverage != nil) {
+		t.Errorf("Python3 = %+v", python)
+	}
+	if want := []string{ModeRun, ModeTrace, ModeTest, ModeJudge, ModeRepl}; !reflect.DeepEqual(python.Modes, want) {
+		t.Errorf("Python3 modes = %v, want %v", python.Modes, want)
+	}
+
+	kotlin := byName[KOTLIN]
+	if kotlin.Format || kotlin.Coverage {
+		t.Errorf("Kotlin = %+v", kotlin)
+	}
+	if want := []string{ModeRun, ModeJudge}; !reflect.DeepEqual(kotlin.Modes, want) {
+		t.Errorf("Kotlin modes = %v, want %v", kotlin.Modes, want)
+	}
+
+	if project := byName[PROJECT]; project.Filename != "" || !reflect.DeepEqual(project.Modes, []string{ModeRun}) {
+		t.Errorf("Project = %+v", project)
+	}
+}
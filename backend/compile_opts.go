@@ -4,8 +4,180 @@ type CompileOption struct {
 	Filename   string
 	CompileCmd []string
 	ExecuteCmd []string
+	// stdin 으로 소스를 받아 stdout 으로 정리한 소스를 출력하는 포매터. 비어 있으면 format 을 지원하지 않는다.
+	FormatCmd []string
+	// 실행 시 isolate 에 추가로 넘길 인자 (환경 변수, 제한 등)
+	IsolateArgs []string
+	// stderr 의 ASan/UBSan 리포트를 파싱해서 sanitizer_report 이벤트로 보낸다.
+	Sanitizer bool
+	// 실행이 끝나면 sqlResultsFile 의 결과 집합을 sql_result 이벤트로 보낸다.
+	SQL bool
+	// 같은 소스와 명령으로 컴파일한 산출물을 캐시에서 꺼내 쓴다. (compilecache.go)
+	CompileCache bool
+	// 미리 컴파일한 표준 라이브러리 GOCACHE 를 workspace 에 펼친 뒤 컴파일한다. (gocache.go)
+	GoCache bool
+	// javac 데몬과 CDS 아카이브 설정을 따른다. (javacd.go)
+	JavacDaemon bool
+	// 소스에서 패키지와 main 클래스를 찾아 파일 경로와 실행 클래스를 정한다. (Java)
+	DetectJavaEntry bool
+	// judge 에서 input_file 이 없을 때 테스트 케이스 입력을 stdin 대신 쓸 workspace 파일
+	JudgeInputFile string
+	// trace 모드에서 실행할 tracer. 비어 있으면 trace 모드를 지원하지 않는다.
+	TraceCmd []string
+	// repl 모드에서 셀을 받아 실행하는 드라이버
+	ReplCmd []string
+	// debug 모드에서 사용하는 디버그 빌드 명령과 gdb 로 띄울 실행 파일
+	DebugCompileCmd []string
+	DebugTarget     string
+	// test 모드 설정. nil 이면 test 모드를 지원하지 않는다.
+	Test *TestOption
+	// coverage 설정. nil 이면 coverage 를 지원하지 않는다.
+	Coverage *CoverageOption
+
+	// 업로드된 트리의 빌드 시스템을 감지해서 빌드/실행한다. Filename 과 CompileCmd 는 쓰지 않는다.
+	Project bool
+
+	// WithCoverage 로 만든 복사본에서만 true
+	coverage bool
+}
+
+// BuildSystem 은 project 모드에서 업로드된 트리를 빌드하는 방법이다.
+// BuildCmds 는 /code 에서 isolate 안에 차례로 실행되고, SourceExt 가 있으면
+// 마지막 명령 뒤에 해당 확장자의 소스 파일들이 붙는다.
+// 실행 명령은 manifest(iris.json) 의 run 이 우선이고, 없으면 DefaultRun 중 실행 파일이 있는 것을 쓴다.
+type BuildSystem struct {
+	Name       string
+	Markers    []string
+	SourceExt  string
+	BuildCmds  [][]string
+	DefaultRun [][]string
+}
+
+const projectManifest = "iris.json"
+
+var BuildSystems = []BuildSystem{
+	{
+		Name:       "cmake",
+		Markers:    []string{"CMakeLists.txt"},
+		BuildCmds:  [][]string{{"/usr/bin/cmake", "-S", "/code", "-B", "/code/build"}, {"/usr/bin/cmake", "--build", "/code/build"}},
+		DefaultRun: [][]string{{"/code/build/main"}},
+	},
+	{
+		Name:       "make",
+		Markers:    []string{"Makefile", "makefile", "GNUmakefile"},
+		BuildCmds:  [][]string{{"/usr/bin/make", "-C", "/code"}},
+		DefaultRun: [][]string{{"/code/main"}, {"/code/a.out"}},
+	},
+	{
+		Name:       "go",
+		Markers:    []string{"go.mod"},
+		BuildCmds:  [][]string{{"/usr/bin/go", "build", "-o", "/code/.build/main", "."}},
+		DefaultRun: [][]string{{"/code/.build/main"}},
+	},
+	{
+		Name:       "java",
+		SourceExt:  ".java",
+		BuildCmds:  [][]string{{"/usr/bin/javac", "-d", "/code/.build"}},
+		DefaultRun: [][]string{{"/usr/bin/java", "-cp", "/code/.build", "Main"}},
+	},
+}
+
+// CoverageOption 의 명령들은 coverage 요청 시 CompileOption/TestOption 의 같은 이름 명령을 대신한다.
+// 실행이 끝나면 CollectCmd 를 isolate 안에서 실행하고, 결과는 ReportPath(비어 있으면 stdout) 에서 읽는다.
+type CoverageOption struct {
+	CompileCmd     []string
+	ExecuteCmd     []string
+	IsolateArgs    []string
+	TestCompileCmd []string
+	TestExecuteCmd []string
+	CollectCmd     []string
+	ReportPath     string
+	Format         string
 }
 
+const (
+	CoverageGcov     = "gcov-json"
+	CoverageGo       = "go-coverprofile"
+	CoveragePy       = "coverage-py-json"
+	coverageDataDir  = "/code/.coverage-data"
+	coverageDataFile = "/code/.coverage"
+)
+
+// WithCoverage 는 coverage 빌드/실행 명령으로 바꾼 복사본을 돌려준다.
+func (o CompileOption) WithCoverage() CompileOption {
+	cov := o.Coverage
+	if cov == nil {
+		return o
+	}
+
+	o.coverage = true
+	if cov.CompileCmd != nil {
+		o.CompileCmd = cov.CompileCmd
+	}
+	if cov.ExecuteCmd != nil {
+		o.ExecuteCmd = cov.ExecuteCmd
+	}
+	o.IsolateArgs = append(append([]string{}, o.IsolateArgs...), cov.IsolateArgs...)
+
+	if o.Test != nil {
+		test := *o.Test
+		if cov.TestCompileCmd != nil {
+			test.CompileCmd = cov.TestCompileCmd
+		}
+		if cov.TestExecuteCmd != nil {
+			test.ExecuteCmd = cov.TestExecuteCmd
+		}
+		o.Test = &test
+	}
+	return o
+}
+
+// TestOption 은 CompileOption 과 같은 compile/execute 구조로 테스트를 빌드하고 실행한다.
+// CompileCmd 뒤에는 workspace 에서 SourceExt 로 끝나는 파일들이 붙는다.
+type TestOption struct {
+	CompileCmd []string
+	SourceExt  string
+	ExecuteCmd []string
+	// ExecuteCmd 결과 형식: TestReportGoJSON 이면 stdout, TestReportJUnit 이면 ReportPath 의 XML
+	Report     string
+	ReportPath string
+}
+
+const (
+	TestReportGoJSON = "go-test-json"
+	TestReportJUnit  = "junit-xml"
+)
+
+const junitConsoleJar = "/opt/junit-platform-console-standalone.jar"
+
+func (o CompileOption) SupportsMode(mode string) bool {
+	switch mode {
+	case ModeRun:
+		return len(o.ExecuteCmd) > 0 || o.Project
+	case ModeTrace:
+		return len(o.TraceCmd) > 0
+	case ModeDebug:
+		return len(o.DebugCompileCmd) > 0 && o.DebugTarget != ""
+	case ModeTest:
+		return o.Test != nil
+	case ModeJudge:
+		return len(o.ExecuteCmd) > 0
+	case ModeRepl:
+		return len(o.ReplCmd) > 0
+	}
+	return false
+}
+
+// code 메시지의 실행 모드
+const (
+	ModeRun   = "run"
+	ModeTrace = "trace"
+	ModeDebug = "debug"
+	ModeTest  = "test"
+	ModeJudge = "judge"
+	ModeRepl  = "repl"
+)
+
 const (
 	C          = "C"
 	CPP        = "Cpp"
@@ -13,37 +185,194 @@ const (
 	GO         = "Go"
 	PYTHON     = "Python3"
 	JAVASCRIPT = "Javascript"
+	RUST       = "Rust"
+	KOTLIN     = "Kotlin"
+	CSHARP     = "CSharp"
+	TYPESCRIPT = "Typescript"
+
+	C_SANITIZER   = "C-Sanitizer"
+	CPP_SANITIZER = "Cpp-Sanitizer"
+
+	PROJECT = "Project"
+	SQL     = "SQL"
+)
+
+// ASan 은 shadow memory 를 위해 수 TB 의 가상 주소 공간을 예약하므로
+// sanitizer 빌드에는 --mem(RLIMIT_AS) 을 걸면 안 된다. 대신 RSS 기준으로 제한한다.
+// stdbuf 가 LD_PRELOAD 를 사용하므로 link order 검사는 끈다.
+var sanitizerIsolateArgs = []string{
+	"--env=ASAN_OPTIONS=detect_leaks=0:verify_asan_link_order=0:hard_rss_limit_mb=384:allocator_may_return_null=1",
+	"--env=UBSAN_OPTIONS=print_stacktrace=1",
+}
+
+// JVM, mono, node 는 GC 와 JIT 스레드를 여러 개 만들기 때문에 --processes 가 필요하다.
+// --mem 은 주소 공간 제한이라 큰 가상 메모리를 예약하는 런타임이 뜨지 못하므로
+// 힙 크기는 각 런타임의 옵션으로 제한한다.
+var (
+	jvmIsolateArgs  = []string{"--processes"}
+	monoIsolateArgs = []string{"--processes", "--dir=/etc/mono", "--env=MONO_GC_PARAMS=max-heap-size=256m"}
+	nodeIsolateArgs = []string{"--processes"}
+	// Java 실행 명령을 새로 만들 때(javaentry.go) 붙이는 JVM 옵션. JAVA, KOTLIN 의 ExecuteCmd 와 같다.
+	jvmRunArgs = []string{"-Xmx256m", "-Xss64m", "-XX:+UseSerialGC"}
 )
 
 var CompileOptions = map[string]CompileOption{
+	PROJECT: {
+		Project:     true,
+		IsolateArgs: []string{"--processes"},
+	},
 	C: {
-		Filename:   "/code/main.c",
-		CompileCmd: []string{"/usr/bin/gcc", "-o", "/code/main", "/code/main.c"},
-		ExecuteCmd: []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		Filename:     "/code/main.c",
+		CompileCache: true,
+		CompileCmd:   []string{"/usr/bin/gcc", "-o", "/code/main", "/code/main.c"},
+		ExecuteCmd:   []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		FormatCmd:    []string{"/usr/bin/clang-format", "--assume-filename=main.c"},
+
+		DebugCompileCmd: []string{"/usr/bin/gcc", "-g", "-O0", "-o", "/code/main", "/code/main.c"},
+		DebugTarget:     "/code/main",
+		Coverage: &CoverageOption{
+			CompileCmd: []string{"/usr/bin/gcc", "--coverage", "-o", "/code/main", "/code/main.c"},
+			CollectCmd: []string{"/usr/bin/gcov", "--json-format", "--stdout", "/code/main-main.gcno"},
+			Format:     CoverageGcov,
+		},
 	},
 	CPP: {
-		Filename:   "/code/main.cpp",
-		CompileCmd: []string{"/usr/bin/g++", "-o", "/code/main", "/code/main.cpp"},
-		ExecuteCmd: []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		Filename:     "/code/main.cpp",
+		CompileCache: true,
+		CompileCmd:   []string{"/usr/bin/g++", "-I/opt/pch", "-o", "/code/main", "/code/main.cpp"},
+		ExecuteCmd:   []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		FormatCmd:    []string{"/usr/bin/clang-format", "--assume-filename=main.cpp"},
+
+		DebugCompileCmd: []string{"/usr/bin/g++", "-I/opt/pch", "-g", "-O0", "-o", "/code/main", "/code/main.cpp"},
+		DebugTarget:     "/code/main",
+		Coverage: &CoverageOption{
+			CompileCmd: []string{"/usr/bin/g++", "-I/opt/pch", "--coverage", "-o", "/code/main", "/code/main.cpp"},
+			CollectCmd: []string{"/usr/bin/gcov", "--json-format", "--stdout", "/code/main-main.gcno"},
+			Format:     CoverageGcov,
+		},
+	},
+	C_SANITIZER: {
+		Filename:     "/code/main.c",
+		CompileCache: true,
+		CompileCmd:   []string{"/usr/bin/gcc", "-fsanitize=address,undefined", "-g", "-fno-omit-frame-pointer", "-o", "/code/main", "/code/main.c"},
+		ExecuteCmd:   []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		FormatCmd:    []string{"/usr/bin/clang-format", "--assume-filename=main.c"},
+		IsolateArgs:  sanitizerIsolateArgs,
+		Sanitizer:    true,
+	},
+	CPP_SANITIZER: {
+		Filename:     "/code/main.cpp",
+		CompileCache: true,
+		CompileCmd:   []string{"/usr/bin/g++", "-I/opt/pch", "-fsanitize=address,undefined", "-g", "-fno-omit-frame-pointer", "-o", "/code/main", "/code/main.cpp"},
+		ExecuteCmd:   []string{"/usr/bin/stdbuf", "-o0", "/code/main"},
+		FormatCmd:    []string{"/usr/bin/clang-format", "--assume-filename=main.cpp"},
+		IsolateArgs:  sanitizerIsolateArgs,
+		Sanitizer:    true,
 	},
 	JAVA: {
-		Filename:   "/code/Main.java",
-		CompileCmd: []string{"/usr/bin/javac", "/code/Main.java"},
-		ExecuteCmd: []string{"/usr/bin/java", "-cp", "/code", "Main"},
+		Filename:        "/code/Main.java",
+		CompileCmd:      []string{"/usr/bin/javac", "/code/Main.java"},
+		ExecuteCmd:      []string{"/usr/bin/java", "-Xmx256m", "-Xss64m", "-XX:+UseSerialGC", "-cp", "/code", "Main"},
+		FormatCmd:       []string{"/usr/bin/java", "-jar", "/opt/google-java-format.jar", "-"},
+		IsolateArgs:     jvmIsolateArgs,
+		DetectJavaEntry: true,
+		JavacDaemon:     true,
+		Test: &TestOption{
+			CompileCmd: []string{"/usr/bin/javac", "-cp", junitConsoleJar, "-d", "/code"},
+			SourceExt:  ".java",
+			ExecuteCmd: []string{"/usr/bin/java", "-jar", junitConsoleJar, "--class-path", "/code", "--scan-class-path", "--disable-banner", "--details=none", "--reports-dir=/code/.test-reports"},
+			Report:     TestReportJUnit,
+			ReportPath: "/code/.test-reports/TEST-junit-jupiter.xml",
+		},
 	},
 	GO: {
 		Filename:   "/code/main.go",
 		CompileCmd: []string{"/usr/bin/go", "build", "-o", "/code/main", "/code/main.go"},
 		ExecuteCmd: []string{"/code/main"},
+		FormatCmd:  []string{"/usr/bin/gofmt"},
+		GoCache:    true,
+		Test: &TestOption{
+			CompileCmd: []string{"/usr/bin/go", "test", "-c", "-o", "/code/main.test"},
+			SourceExt:  ".go",
+			ExecuteCmd: []string{"/usr/bin/go", "tool", "test2json", "-t", "/code/main.test", "-test.v=test2json"},
+			Report:     TestReportGoJSON,
+		},
+		Coverage: &CoverageOption{
+			CompileCmd:     []string{"/usr/bin/go", "build", "-cover", "-o", "/code/main", "/code/main.go"},
+			IsolateArgs:    []string{"--env=GOCOVERDIR=" + coverageDataDir},
+			TestCompileCmd: []string{"/usr/bin/go", "test", "-c", "-cover", "-o", "/code/main.test"},
+			TestExecuteCmd: []string{"/usr/bin/go", "tool", "test2json", "-t", "/code/main.test", "-test.v=test2json", "-test.gocoverdir=" + coverageDataDir},
+			CollectCmd:     []string{"/usr/bin/go", "tool", "covdata", "textfmt", "-i=" + coverageDataDir, "-o=/code/.coverage.out"},
+			ReportPath:     "/code/.coverage.out",
+			Format:         CoverageGo,
+		},
 	},
 	PYTHON: {
 		Filename:   "/code/main.py",
 		CompileCmd: []string{},
 		ExecuteCmd: []string{"/usr/bin/python3", "/code/main.py"},
+		FormatCmd:  []string{"/usr/bin/black", "--quiet", "-"},
+		TraceCmd:   []string{"/usr/bin/python3", "/usr/local/lib/iris/pytrace.py"},
+		ReplCmd:    []string{"/usr/bin/python3", "/usr/local/lib/iris/pyrepl.py"},
+		Test: &TestOption{
+			ExecuteCmd: []string{"/usr/bin/python3", "-m", "pytest", "-q", "-p", "no:cacheprovider", "--junitxml=/code/.test-report.xml", "/code"},
+			Report:     TestReportJUnit,
+			ReportPath: "/code/.test-report.xml",
+		},
+		Coverage: &CoverageOption{
+			ExecuteCmd:     []string{"/usr/bin/python3", "-m", "coverage", "run", "--data-file=" + coverageDataFile, "/code/main.py"},
+			TestExecuteCmd: []string{"/usr/bin/python3", "-m", "coverage", "run", "--data-file=" + coverageDataFile, "-m", "pytest", "-q", "-p", "no:cacheprovider", "--junitxml=/code/.test-report.xml", "/code"},
+			CollectCmd:     []string{"/usr/bin/python3", "-m", "coverage", "json", "--data-file=" + coverageDataFile, "-o", "/code/.coverage.json"},
+			ReportPath:     "/code/.coverage.json",
+			Format:         CoveragePy,
+		},
+	},
+	SQL: {
+		Filename:   "/code/main.sql",
+		CompileCmd: []string{},
+		ExecuteCmd: []string{
+			"/usr/bin/python3", "/usr/local/lib/iris/sqlrun.py",
+			"--database", sqlDatabaseFile, "--results", sqlResultsFile,
+			"--setup", sqlSchemaFile, "--setup", sqlSeedFile, "--setup", workspaceDir + "/" + sqlCaseFile,
+			"/code/main.sql",
+		},
+		SQL:            true,
+		JudgeInputFile: sqlCaseFile,
 	},
 	JAVASCRIPT: {
 		Filename:   "/code/main.js",
 		CompileCmd: []string{},
 		ExecuteCmd: []string{"/usr/bin/node", "/code/main.js"},
+		FormatCmd:  []string{"/usr/local/bin/prettier", "--stdin-filepath", "main.js"},
+		ReplCmd:    []string{"/usr/bin/node", "/usr/local/lib/iris/noderepl.js"},
+	},
+	RUST: {
+		Filename:     "/code/main.rs",
+		CompileCache: true,
+		CompileCmd:   []string{"/usr/bin/rustc", "--edition=2021", "-O", "-o", "/code/main", "/code/main.rs"},
+		ExecuteCmd:   []string{"/code/main"},
+		FormatCmd:    []string{"/usr/bin/rustfmt", "--edition", "2021"},
+
+		DebugCompileCmd: []string{"/usr/bin/rustc", "--edition=2021", "-g", "-C", "opt-level=0", "-o", "/code/main", "/code/main.rs"},
+		DebugTarget:     "/code/main",
+	},
+	KOTLIN: {
+		Filename:    "/code/Main.kt",
+		CompileCmd:  []string{"/usr/local/bin/kotlinc", "/code/Main.kt", "-include-runtime", "-d", "/code/main.jar"},
+		ExecuteCmd:  []string{"/usr/bin/java", "-Xmx256m", "-Xss64m", "-XX:+UseSerialGC", "-jar", "/code/main.jar"},
+		IsolateArgs: jvmIsolateArgs,
+	},
+	CSHARP: {
+		Filename:    "/code/main.cs",
+		CompileCmd:  []string{"/usr/bin/mcs", "-out:/code/main.exe", "/code/main.cs"},
+		ExecuteCmd:  []string{"/usr/bin/mono", "/code/main.exe"},
+		IsolateArgs: monoIsolateArgs,
+	},
+	TYPESCRIPT: {
+		Filename:    "/code/main.ts",
+		CompileCmd:  []string{"/usr/local/bin/tsc", "--pretty", "false", "--target", "es2020", "--module", "commonjs", "--outDir", "/code", "/code/main.ts"},
+		ExecuteCmd:  []string{"/usr/bin/node", "--max-old-space-size=256", "/code/main.js"},
+		FormatCmd:   []string{"/usr/local/bin/prettier", "--stdin-filepath", "main.ts"},
+		IsolateArgs: nodeIsolateArgs,
 	},
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 컴파일 결과는 컴파일 명령과 workspace 의 모든 입력 파일을 해시한 키로 캐시한다.
+// 같은 코드를 다시 실행하면 컴파일러를 띄우지 않고 산출물(실행 파일 등)을 workspace 에 복사한다.
+// 산출물은 컴파일 전후 workspace 를 비교해서 새로 생기거나 바뀐 파일이다.
+const (
+	compileCacheDir      = "/var/cache/iris/compile"
+	compileCacheMaxBytes = 512 * 1024 * 1024
+	compileCacheMaxEntry = 64 * 1024 * 1024
+	compileOutputFile    = ".compile-output"
+)
+
+var compileCacheMu sync.Mutex
+
+// compileWithCache 는 캐시에 있으면 산출물을 복원하고, 없으면 컴파일한 뒤 성공한 결과만 저장한다.
+func compileWithCache(box *Box, compileCmd []string) (string, bool, error) {
+	key, err := compileCacheKey(box, compileCmd)
+	if err != nil {
+		log.Println("compile cache key error:", err)
+		output, compileErr := box.runCommand(compileCmd)
+		return output, false, compileErr
+	}
+
+	if output, ok := restoreCompileCache(box, key); ok {
+		return output, true, nil
+	}
+
+	before, err := listWorkspace(box, nil)
+	if err != nil {
+		output, compileErr := box.runCommand(compileCmd)
+		return output, false, compileErr
+	}
+	output, compileErr := box.runCommand(compileCmd)
+	if compileErr != nil {
+		return output, false, compileErr
+	}
+	if err := storeCompileCache(box, key, output, before); err != nil {
+		log.Println("compile cache store error:", err)
+	}
+	return output, false, nil
+}
+
+// 파일 경로는 box 마다 다른 호스트 경로가 아니라 workspace 상대 경로로 해시한다.
+func compileCacheKey(box *Box, compileCmd []string) (string, error) {
+	h := sha256.New()
+	for _, arg := range compileCmd {
+		_, _ = io.WriteString(h, arg)
+		_, _ = h.Write([]byte{0})
+	}
+
+	var files []string
+	err := filepath.WalkDir(box.Workspace, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(box.Workspace, path)
+		fmt.Fprintf(h, "%s\x00%o\x00%d\x00", rel, info.Mode().Perm(), info.Size())
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func restoreCompileCache(box *Box, key string) (string, bool) {
+	compileCacheMu.Lock()
+	defer compileCacheMu.Unlock()
+
+	entry := filepath.Join(compileCacheDir, key)
+	output, err := os.ReadFile(filepath.Join(entry, compileOutputFile))
+	if err != nil {
+		return "", false
+	}
+	err = filepath.WalkDir(entry, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(entry, path)
+		if d.IsDir() || rel == compileOutputFile {
+			return nil
+		}
+		return copyFile(path, filepath.Join(box.Workspace, rel))
+	})
+	if err != nil {
+		log.Println("compile cache restore error:", err)
+		return "", false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(entry, now, now)
+	return string(output), true
+}
+
+func storeCompileCache(box *Box, key string, output string, before map[string]WorkspaceEntry) error {
+	after, err := listWorkspace(box, nil)
+	if err != nil {
+		return err
+	}
+
+	var artifacts []string
+	var size int64
+	for rel, entry := range after {
+		if prev, ok := before[rel]; ok && prev.Size == entry.Size && prev.Modified.Equal(entry.Modified) {
+			continue
+		}
+		artifacts = append(artifacts, rel)
+		size += entry.Size
+	}
+	if len(artifacts) == 0 || size > compileCacheMaxEntry {
+		return nil
+	}
+
+	compileCacheMu.Lock()
+	defer compileCacheMu.Unlock()
+
+	if err := os.MkdirAll(compileCacheDir, 0o755); err != nil {
+		return err
+	}
+	// 다 쓴 뒤에 이름을 바꿔서 반쯤 쓰인 항목이 캐시에 보이지 않게 한다.
+	tmp, err := os.MkdirTemp(compileCacheDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, rel := range artifacts {
+		if err := copyFile(filepath.Join(box.Workspace, rel), filepath.Join(tmp, rel)); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, compileOutputFile), []byte(output), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(compileCacheDir, key)); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	evictCompileCache()
+	return nil
+}
+
+// evictCompileCache 는 가장 오래 쓰이지 않은 항목부터 지워서 전체 크기를 한도 아래로 맞춘다.
+// compileCacheMu 를 잡은 상태에서 호출한다.
+func evictCompileCache() {
+	entries, err := os.ReadDir(compileCacheDir)
+	if err != nil {
+		return
+	}
+
+	type cacheEntry struct {
+		path    string
+		size    int64
+		lastUse time.Time
+	}
+	var list []cacheEntry
+	var total int64
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(compileCacheDir, e.Name())
+		size := dirSize(path)
+		list = append(list, cacheEntry{path: path, size: size, lastUse: info.ModTime()})
+		total += size
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].lastUse.Before(list[j].lastUse) })
+	for _, e := range list {
+		if total <= compileCacheMaxBytes {
+			break
+		}
+		if err := os.RemoveAll(e.path); err == nil {
+			total -= e.size
+		}
+	}
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// copyFile 은 권한 비트를 유지해서 복사한다. 실행 파일이 실행 권한을 잃지 않게 한다.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
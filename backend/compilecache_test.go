@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyFileKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main")
+	if err := os.WriteFile(src, []byte("binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "cache", "entry", "main")
+	if err := copyFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "binary" {
+		t.Errorf("data = %q", data)
+	}
+	if size := dirSize(filepath.Join(dir, "cache")); size != int64(len("binary")) {
+		t.Errorf("dirSize = %d", size)
+	}
+}
+
+func TestCompileCacheKey(t *testing.T) {
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	path := filepath.Join(workspaceDir, "main.c")
+	if err := os.WriteFile(box.path(path), []byte("int main() { return 0; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	compileCmd := []string{"/usr/bin/gcc", "-o", "/code/main", path}
+	key, err := compileCacheKey(box, compileCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := compileCacheKey(box, compileCmd); again != key {
+		t.Error("key changed without changes")
+	}
+	if other, _ := compileCacheKey(box, []string{"/usr/bin/gcc", "-O2", "-o", "/code/main", path}); other == key {
+		t.Error("key did not change with the compile command")
+	}
+	// 인자 경계가 바뀌면 다른 키여야 한다.
+	if other, _ := compileCacheKey(box, []string{"/usr/bin/gcc -o", "/code/main", path}); other == key {
+		t.Error("key did not change with argument boundaries")
+	}
+
+	if err := os.WriteFile(box.path(path), []byte("int main() { return 1; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := compileCacheKey(box, compileCmd); changed == key {
+		t.Error("key did not change with the source")
+	}
+}
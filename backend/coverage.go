@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const coverageReportLimit = 4 * 1024 * 1024
+
+// 파일 경로 -> 줄 번호 -> 실행 횟수. 실행되지 않은 줄은 0 이다.
+type CoverageReport map[string]map[int]int
+
+// collectCoverage 는 실행이 끝난 뒤 coverage 산출물을 읽어 줄 단위 실행 횟수로 바꾼다.
+// exclude 에 있는 파일(출제자 테스트 등)은 결과에서 뺀다.
+func collectCoverage(box *Box, cov *CoverageOption, exclude map[string]bool) (CoverageReport, error) {
+	args := box.isolateArgs()
+	args = append(args,
+		"--processes",
+		"--time=10",
+		"--wall-time=20",
+		"--env=HOME=/box",
+		"--silent",
+		"--run", "--",
+	)
+	args = append(args, cov.CollectCmd...)
+
+	stdout := &limitedBuffer{limit: coverageReportLimit}
+	stderr := &limitedBuffer{limit: 16 * 1024}
+	cmd := exec.Command(isolateBinary, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	data := stdout.Bytes()
+	if cov.ReportPath != "" {
+		// 리포트는 샌드박스가 쓴 파일이라 symlink 를 따라가지 않고 크기를 제한해서 읽는다.
+		var err error
+		if data, err = box.readFile(box.path(cov.ReportPath), coverageReportLimit); err != nil {
+			return nil, err
+		}
+	}
+
+	var report CoverageReport
+	var err error
+	switch cov.Format {
+	case CoverageGcov:
+		report, err = parseGcovJSON(data)
+	case CoverageGo:
+		report, err = parseGoCoverProfile(data)
+	case CoveragePy:
+		report, err = parseCoveragePyJSON(data)
+	default:
+		err = fmt.Errorf("unknown coverage format: %s", cov.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for file := range report {
+		if exclude[file] || !strings.HasPrefix(file, workspaceDir+"/") || strings.HasPrefix(filepath.Base(file), ".") {
+			delete(report, file)
+		}
+	}
+	return report, nil
+}
+
+// coverage 도구들이 상대 경로나 패키지 경로로 파일을 표시하는 경우 workspace 경로로 맞춘다.
+func coverageFilePath(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(workspaceDir, filepath.Clean(name))
+}
+
+func (r CoverageReport) add(file string, line int, count int) {
+	lines, ok := r[file]
+	if !ok {
+		lines = map[int]int{}
+		r[file] = lines
+	}
+	if prev, seen := lines[line]; !seen || count > prev {
+		lines[line] = count
+	}
+}
+
+type gcovReport struct {
+	Files []struct {
+		File  string `json:"file"`
+		Lines []struct {
+			LineNumber int `json:"line_number"`
+			Count      int `json:"count"`
+		} `json:"lines"`
+	} `json:"files"`
+}
+
+func parseGcovJSON(data []byte) (CoverageReport, error) {
+	report := CoverageReport{}
+	// gcov --stdout 은 입력 파일마다 JSON 문서를 한 줄씩 출력한다.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var doc gcovReport
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+		for _, file := range doc.Files {
+			path := coverageFilePath(file.File)
+			for _, line := range file.Lines {
+				report.add(path, line.LineNumber, line.Count)
+			}
+		}
+	}
+	return report, nil
+}
+
+// mode: set
+// command-line-arguments/main.go:5.13,7.2 1 1
+func parseGoCoverProfile(data []byte) (CoverageReport, error) {
+	report := CoverageReport{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid cover profile line: %q", line)
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid cover profile line: %q", line)
+		}
+
+		var startLine, startCol, endLine, endCol int
+		if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &startLine, &startCol, &endLine, &endCol); err != nil {
+			return nil, fmt.Errorf("invalid cover block %q: %w", fields[0], err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cover count %q: %w", fields[2], err)
+		}
+
+		// 패키지 경로(command-line-arguments/main.go) 는 workspace 의 파일 이름으로 바꾼다.
+		path := filepath.Join(workspaceDir, filepath.Base(line[:colon]))
+		for l := startLine; l <= endLine; l++ {
+			report.add(path, l, count)
+		}
+	}
+	return report, scanner.Err()
+}
+
+type coveragePyReport struct {
+	Files map[string]struct {
+		ExecutedLines []int `json:"executed_lines"`
+		MissingLines  []int `json:"missing_lines"`
+	} `json:"files"`
+}
+
+// coverage.py 는 실행 횟수를 기록하지 않으므로 실행된 줄은 1 로 표시한다.
+func parseCoveragePyJSON(data []byte) (CoverageReport, error) {
+	var doc coveragePyReport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	report := CoverageReport{}
+	for name, file := range doc.Files {
+		path := coverageFilePath(name)
+		for _, line := range file.ExecutedLines {
+			report.add(path, line, 1)
+		}
+		for _, line := range file.MissingLines {
+			report.add(path, line, 0)
+		}
+	}
+	return report, nil
+}
+
+func sendCoverage(ctx *ConnectionContext, cov *CoverageOption, exclude map[string]bool) {
+	report, err := collectCoverage(ctx.box, cov, exclude)
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "coverage_error",
+			"error": fmt.Sprintf("failed to collect coverage: %v", err),
+		})
+		return
+	}
+	ctx.write(map[string]interface{}{
+		"type":  "coverage",
+		"files": report,
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGcovJSON(t *testing.T) {
+	data := []byte(`{"files":[{"file":"/code/main.c","lines":[{"line_number":3,"count":1},{"line_number":4,"count":0}]}]}
+{"files":[{"file":"main.c","lines":[{"line_number":4,"count":2}]}]}
+`)
+	report, err := parseGcovJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CoverageReport{"/code/main.c": {3: 1, 4: 2}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %v, want %v", report, want)
+	}
+}
+
+func TestParseGoCoverProfile(t *testing.T) {
+	data := []byte("mode: count\ncommand-line-arguments/main.go:5.13,7.2 1 3\ncommand-line-arguments/main.go:9.2,9.10 1 0\n")
+	report, err := parseGoCoverProfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CoverageReport{"/code/main.go": {5: 3, 6: 3, 7: 3, 9: 0}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %v, want %v", report, want)
+	}
+
+	if _, err := parseGoCoverProfile([]byte("main.go:1.1,2.2 x\n")); err == nil {
+		t.Error("invalid profile was accepted")
+	}
+}
+
+func TestParseCoveragePyJSON(t *testing.T) {
+	data := []byte(`{"files":{"main.py":{"executed_lines":[1,2],"missing_lines":[4]}}}`)
+	report, err := parseCoveragePyJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CoverageReport{"/code/main.py": {1: 1, 2: 1, 4: 0}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %v, want %v", report, want)
+	}
+}
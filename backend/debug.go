@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// 디버그 대상 프로그램의 표준 입출력은 gdb/MI 채널과 분리하기 위해 workspace 의 FIFO 로 연결한다.
+const (
+	debugStdinFifo  = ".debug_stdin"
+	debugStdoutFifo = ".debug_stdout"
+	debugStderrFifo = ".debug_stderr"
+	gdbBinary       = "/usr/bin/gdb"
+	libstdbufPath   = "/usr/libexec/coreutils/libstdbuf.so"
+)
+
+type debugSession struct {
+	ctx        *ConnectionContext
+	cmd        *exec.Cmd
+	mi         io.WriteCloser
+	sourceFile string
+	fifos      []*os.File
+
+	mu        sync.Mutex
+	nextToken int
+	pending   map[int]string
+	exited    bool
+	closeOnce sync.Once
+}
+
+func startDebugSession(ctx *ConnectionContext, option CompileOption, breakpoints []int) error {
+	fifoFiles := make([]*os.File, 0, 3)
+	closeFifos := func() {
+		for _, f := range fifoFiles {
+			_ = f.Close()
+		}
+	}
+
+	// O_RDWR 로 열면 반대편이 아직 열리지 않아도 블록되지 않는다.
+	for _, name := range []string{debugStdinFifo, debugStdoutFifo, debugStderrFifo} {
+		path := filepath.Join(ctx.box.Workspace, name)
+		if err := syscall.Mkfifo(path, 0o666); err != nil {
+			closeFifos()
+			return fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		// Mkfifo 의 mode 는 umask 로 줄어드므로 샌드박스 사용자가 열 수 있게 다시 지정한다.
+		if err := os.Chmod(path, 0o666); err != nil {
+			closeFifos()
+			return fmt.Errorf("failed to chmod %s: %w", name, err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			closeFifos()
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		fifoFiles = append(fifoFiles, f)
+	}
+
+	args := ctx.box.isolateArgs()
+	args = append(args,
+		"--processes",
+		"--time=60",
+		"--wall-time=600",
+		"--env=HOME=/box",
+		"--run", "--",
+		gdbBinary, "--interpreter=mi3", "--nx", "--quiet", option.DebugTarget,
+	)
+
+	cmd := exec.Command(isolateBinary, args...)
+	miIn, err := cmd.StdinPipe()
+	if err != nil {
+		closeFifos()
+		return err
+	}
+	miOut, err := cmd.StdoutPipe()
+	if err != nil {
+		closeFifos()
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		closeFifos()
+		return err
+	}
+
+	session := &debugSession{
+		ctx:        ctx,
+		cmd:        cmd,
+		mi:         miIn,
+		sourceFile: option.Filename,
+		fifos:      fifoFiles,
+		pending:    make(map[int]string),
+	}
+	ctx.setProcess(cmd, fifoFiles[0])
+	ctx.setDebugSession(session)
+
+	go streamOutput(ctx, fifoFiles[1], "stdout", nil)
+	go streamOutput(ctx, fifoFiles[2], "stderr", nil)
+	go session.readMI(miOut)
+	go func() {
+		_ = cmd.Wait()
+		session.finish(-1, "debugger exited")
+	}()
+
+	stdinPath := filepath.Join(workspaceDir, debugStdinFifo)
+	stdoutPath := filepath.Join(workspaceDir, debugStdoutFifo)
+	stderrPath := filepath.Join(workspaceDir, debugStderrFifo)
+	session.send("", "-gdb-set mi-async on")
+	session.send("", "-gdb-set disable-randomization off")
+	session.send("", "-gdb-set confirm off")
+	session.send("", "-interpreter-exec console "+miQuote("set environment LD_PRELOAD="+libstdbufPath))
+	session.send("", "-interpreter-exec console "+miQuote("set environment _STDBUF_O=0"))
+	session.send("", fmt.Sprintf("-exec-arguments < %s > %s 2> %s", stdinPath, stdoutPath, stderrPath))
+	for _, line := range breakpoints {
+		session.send("break", fmt.Sprintf("-break-insert %s:%d", session.sourceFile, line))
+	}
+	session.send("", "-exec-run --start")
+
+	return nil
+}
+
+func (s *debugSession) send(kind string, command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exited {
+		return
+	}
+
+	s.nextToken++
+	token := s.nextToken
+	if kind != "" {
+		s.pending[token] = kind
+	}
+	if _, err := fmt.Fprintf(s.mi, "%d%s\n", token, command); err != nil {
+		log.Println("gdb write error:", err)
+	}
+}
+
+func (s *debugSession) takePending(token int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kind := s.pending[token]
+	delete(s.pending, token)
+	return kind
+}
+
+func (s *debugSession) handle(msg *Message) {
+	switch msg.Command {
+	case "break":
+		if msg.Line <= 0 {
+			s.writeError(msg.Command, "line is required")
+			return
+		}
+		s.send("break", fmt.Sprintf("-break-insert %s:%d", s.sourceFile, msg.Line))
+	case "delete":
+		s.send("delete", fmt.Sprintf("-break-delete %d", msg.Breakpoint))
+	case "continue":
+		s.send("", "-exec-continue")
+	case "next":
+		s.send("", "-exec-next")
+	case "step":
+		s.send("", "-exec-step")
+	case "finish":
+		s.send("", "-exec-finish")
+	case "pause":
+		s.send("", "-exec-interrupt")
+	case "stack":
+		s.send("stack", "-stack-list-frames")
+	case "variables":
+		s.send("variables", fmt.Sprintf("-stack-list-variables --thread 1 --frame %d --all-values", msg.Frame))
+	case "evaluate":
+		if msg.Expression == "" {
+			s.writeError(msg.Command, "expression is required")
+			return
+		}
+		s.send("evaluate:"+msg.Expression, fmt.Sprintf("-data-evaluate-expression --thread 1 --frame %d %s", msg.Frame, miQuote(msg.Expression)))
+	default:
+		s.writeError(msg.Command, "unknown debug command")
+	}
+}
+
+func (s *debugSession) readMI(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		record, err := parseMIRecord(scanner.Text())
+		if err != nil {
+			log.Println("gdb MI parse error:", err)
+			continue
+		}
+		if record == nil {
+			continue
+		}
+		s.dispatch(record)
+	}
+}
+
+func (s *debugSession) dispatch(record *miRecord) {
+	switch record.Kind {
+	case '~':
+		s.ctx.write(map[string]interface{}{
+			"type": "debug_console",
+			"data": record.Stream,
+		})
+
+	case '*':
+		switch record.Class {
+		case "running":
+			s.ctx.write(map[string]interface{}{"type": "debug_running"})
+		case "stopped":
+			s.handleStopped(record.Results)
+		}
+
+	case '^':
+		kind := s.takePending(record.Token)
+		if record.Class == "error" {
+			s.writeError(kind, miString(record.Results, "msg"))
+			return
+		}
+		s.handleResult(kind, record.Results)
+	}
+}
+
+func (s *debugSession) handleStopped(results map[string]interface{}) {
+	reason := miString(results, "reason")
+	switch reason {
+	case "exited-normally":
+		s.finish(0, "")
+		return
+	case "exited":
+		code, _ := strconv.ParseInt(miString(results, "exit-code"), 8, 32)
+		s.finish(int(code), "")
+		return
+	case "exited-signalled":
+		s.finish(-1, miString(results, "signal-name"))
+		return
+	}
+
+	event := map[string]interface{}{
+		"type":   "debug_stopped",
+		"reason": reason,
+		"frame":  debugFrame(miTuple(results, "frame")),
+	}
+	if bkpt := miString(results, "bkptno"); bkpt != "" {
+		event["breakpoint"], _ = strconv.Atoi(bkpt)
+	}
+	if signal := miString(results, "signal-name"); signal != "" {
+		event["signal"] = signal
+	}
+	s.ctx.write(event)
+}
+
+func (s *debugSession) handleResult(kind string, results map[string]interface{}) {
+	switch {
+	case kind == "break":
+		bkpt := miTuple(results, "bkpt")
+		number, _ := strconv.Atoi(miString(bkpt, "number"))
+		line, _ := strconv.Atoi(miString(bkpt, "line"))
+		s.ctx.write(map[string]interface{}{
+			"type":       "debug_breakpoint",
+			"breakpoint": number,
+			"line":       line,
+		})
+
+	case kind == "delete":
+		s.ctx.write(map[string]interface{}{"type": "debug_breakpoint_deleted"})
+
+	case kind == "stack":
+		frames := []map[string]interface{}{}
+		for _, item := range miList(results, "stack") {
+			if frame, ok := item.(map[string]interface{}); ok {
+				frames = append(frames, debugFrame(frame))
+			}
+		}
+		s.ctx.write(map[string]interface{}{
+			"type":   "debug_stack",
+			"frames": frames,
+		})
+
+	case kind == "variables":
+		variables := []map[string]interface{}{}
+		for _, item := range miList(results, "variables") {
+			if v, ok := item.(map[string]interface{}); ok {
+				variables = append(variables, map[string]interface{}{
+					"name":  miString(v, "name"),
+					"value": miString(v, "value"),
+					"arg":   miString(v, "arg") == "1",
+				})
+			}
+		}
+		s.ctx.write(map[string]interface{}{
+			"type":      "debug_variables",
+			"variables": variables,
+		})
+
+	case strings.HasPrefix(kind, "evaluate:"):
+		s.ctx.write(map[string]interface{}{
+			"type":       "debug_value",
+			"expression": strings.TrimPrefix(kind, "evaluate:"),
+			"value":      miString(results, "value"),
+		})
+	}
+}
+
+func debugFrame(frame map[string]interface{}) map[string]interface{} {
+	level, _ := strconv.Atoi(miString(frame, "level"))
+	line, _ := strconv.Atoi(miString(frame, "line"))
+	return map[string]interface{}{
+		"level":    level,
+		"function": miString(frame, "func"),
+		"file":     miString(frame, "fullname"),
+		"line":     line,
+	}
+}
+
+func (s *debugSession) writeError(command string, message string) {
+	s.ctx.write(map[string]interface{}{
+		"type":    "debug_error",
+		"command": strings.SplitN(command, ":", 2)[0],
+		"error":   message,
+	})
+}
+
+// finish 는 디버그 대상이 종료되거나 gdb 가 죽었을 때 한 번만 exit 이벤트를 보낸다.
+func (s *debugSession) finish(exitCode int, reason string) {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.exited = true
+		s.mu.Unlock()
+
+		s.ctx.write(map[string]interface{}{
+			"type":        "exit",
+			"return_code": exitCode,
+			"error":       reason,
+		})
+		s.close()
+		s.ctx.finishRun()
+	})
+}
+
+func (s *debugSession) close() {
+	_ = s.mi.Close()
+	for _, f := range s.fifos {
+		_ = f.Close()
+	}
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+}
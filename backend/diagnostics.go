@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxDiagnostics = 100
+
+// Diagnostic 은 컴파일러 출력의 오류/경고 한 건이다.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+var (
+	// gcc, g++, go, javac, kotlinc: file:line[:col]: [severity: ]message
+	gnuDiagnostic = regexp.MustCompile(`^(\S[^:]*):(\d+):(?:(\d+):)? (?:(error|warning|note|info|fatal error)(?:\[(\w+)\])?: )?(.+)$`)
+	// tsc, mcs: file(line,col): severity CODE: message
+	msbuildDiagnostic = regexp.MustCompile(`^(\S[^(]*)\((\d+),(\d+)\): (error|warning|info) (\w+): (.+)$`)
+	// rustc: 첫 줄에 severity 와 message, 다음 "-->" 줄에 위치가 온다.
+	rustcHeader   = regexp.MustCompile(`^(error|warning)(?:\[(\w+)\])?: (.+)$`)
+	rustcLocation = regexp.MustCompile(`^\s*--> (.+):(\d+):(\d+)$`)
+)
+
+// parseDiagnostics 는 알려진 컴파일러 출력 형식의 줄만 골라낸다. 못 읽은 줄은 무시한다.
+func parseDiagnostics(output string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines) && len(diagnostics) < maxDiagnostics; i++ {
+		line := strings.TrimRight(lines[i], "\r")
+
+		if m := rustcHeader.FindStringSubmatch(line); m != nil && i+1 < len(lines) {
+			if loc := rustcLocation.FindStringSubmatch(strings.TrimRight(lines[i+1], "\r")); loc != nil {
+				diagnostics = append(diagnostics, newDiagnostic(loc[1], loc[2], loc[3], m[1], m[2], m[3]))
+				i++
+				continue
+			}
+		}
+		if m := msbuildDiagnostic.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, newDiagnostic(m[1], m[2], m[3], m[4], m[5], m[6]))
+			continue
+		}
+		if m := gnuDiagnostic.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, newDiagnostic(m[1], m[2], m[3], m[4], m[5], m[6]))
+		}
+	}
+	return diagnostics
+}
+
+func newDiagnostic(file, line, column, severity, code, message string) Diagnostic {
+	d := Diagnostic{
+		File:     strings.TrimPrefix(filepath.Clean(file), workspaceDir+"/"),
+		Severity: severity,
+		Code:     code,
+		Message:  strings.TrimSpace(message),
+	}
+	d.Line, _ = strconv.Atoi(line)
+	d.Column, _ = strconv.Atoi(column)
+	switch d.Severity {
+	case "":
+		// go 는 severity 없이 오류만 출력한다.
+		d.Severity = "error"
+	case "fatal error":
+		d.Severity = "error"
+	}
+	return d
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Diagnostic
+	}{
+		{
+			name:   "gcc",
+			output: "/code/main.c: In function 'main':\n/code/main.c:4:5: error: expected ';' before 'return'\n    4 |     return 0;\n/code/main.c:2:7: warning: unused variable 'x' [-Wunused-variable]\n",
+			want: []Diagnostic{
+				{File: "main.c", Line: 4, Column: 5, Severity: "error", Message: "expected ';' before 'return'"},
+				{File: "main.c", Line: 2, Column: 7, Severity: "warning", Message: "unused variable 'x' [-Wunused-variable]"},
+			},
+		},
+		{
+			name:   "fatal error",
+			output: "main.c:1:10: fatal error: foo.h: No such file or directory\n",
+			want:   []Diagnostic{{File: "main.c", Line: 1, Column: 10, Severity: "error", Message: "foo.h: No such file or directory"}},
+		},
+		{
+			name:   "go without severity",
+			output: "# command-line-arguments\n./main.go:5:2: undefined: x\n",
+			want:   []Diagnostic{{File: "main.go", Line: 5, Column: 2, Severity: "error", Message: "undefined: x"}},
+		},
+		{
+			name:   "javac without column",
+			output: "/code/Main.java:3: error: cannot find symbol\n        foo();\n        ^\n1 error\n",
+			want:   []Diagnostic{{File: "Main.java", Line: 3, Severity: "error", Message: "cannot find symbol"}},
+		},
+		{
+			name:   "tsc",
+			output: "main.ts(2,7): error TS2322: Type 'number' is not assignable to type 'string'.\n",
+			want:   []Diagnostic{{File: "main.ts", Line: 2, Column: 7, Severity: "error", Code: "TS2322", Message: "Type 'number' is not assignable to type 'string'."}},
+		},
+		{
+			name:   "rustc",
+			output: "error[E0425]: cannot find value `x` in this scope\n --> /code/main.rs:2:20\n  |\n",
+			want:   []Diagnostic{{File: "main.rs", Line: 2, Column: 20, Severity: "error", Code: "E0425", Message: "cannot find value `x` in this scope"}},
+		},
+		{
+			name:   "crlf",
+			output: "main.c:1:1: error: oops\r\n",
+			want:   []Diagnostic{{File: "main.c", Line: 1, Column: 1, Severity: "error", Message: "oops"}},
+		},
+		{
+			name:   "nothing recognised",
+			output: "collect2: ld returned 1 exit status\n",
+			want:   []Diagnostic{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDiagnostics(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDiagnostics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	fileTransferLimit   = 1024 * 1024
+	manifestInlineLimit = 256 * 1024
+	manifestTotalLimit  = 2 * 1024 * 1024
+	manifestMaxFiles    = 100
+)
+
+type WorkspaceEntry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// listWorkspace 는 사용자에게 보여줄 수 있는 workspace 파일 목록을 돌려준다.
+// 러너가 내부적으로 쓰는 dotfile 과 출제자 테스트 파일처럼 숨긴 파일은 뺀다.
+func listWorkspace(box *Box, hidden map[string]bool) (map[string]WorkspaceEntry, error) {
+	entries := map[string]WorkspaceEntry{}
+	err := filepath.WalkDir(box.Workspace, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == box.Workspace {
+			return nil
+		}
+		rel, _ := filepath.Rel(box.Workspace, path)
+		if strings.HasPrefix(d.Name(), ".") || hidden[rel] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries[rel] = WorkspaceEntry{Path: rel, Size: info.Size(), Modified: info.ModTime()}
+		return nil
+	})
+	return entries, err
+}
+
+func sortedEntries(entries map[string]WorkspaceEntry) []WorkspaceEntry {
+	list := make([]WorkspaceEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+func handleListFiles(ctx *ConnectionContext) {
+	entries, err := listWorkspace(ctx.box, ctx.hiddenFiles())
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "file_error",
+			"error": fmt.Sprintf("failed to list files: %v", err),
+		})
+		return
+	}
+	ctx.write(map[string]interface{}{
+		"type":  "file_list",
+		"files": sortedEntries(entries),
+	})
+}
+
+func handleGetFile(ctx *ConnectionContext, name string) {
+	fail := func(message string) {
+		ctx.write(map[string]interface{}{
+			"type":  "file_error",
+			"path":  name,
+			"error": message,
+		})
+	}
+
+	path, err := ctx.box.workspacePath(name)
+	rel, _ := filepath.Rel(ctx.box.Workspace, path)
+	if err != nil || ctx.hiddenFiles()[rel] || strings.HasPrefix(filepath.Base(path), ".") {
+		fail("invalid file name")
+		return
+	}
+
+	// 프로그램이 workspace 의 어느 경로든 symlink 로 바꿔 놓을 수 있으므로 경로의 어느 부분이든 symlink 이면 거절한다.
+	data, err := ctx.box.readFile(path, fileTransferLimit)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		fail("file not found")
+		return
+	case errors.Is(err, errNotRegularFile):
+		fail("not a regular file")
+		return
+	case errors.Is(err, errFileTooLarge):
+		fail(fmt.Sprintf("file exceeds %d bytes", fileTransferLimit))
+		return
+	case err != nil:
+		fail(fmt.Sprintf("failed to read file: %v", err))
+		return
+	}
+	ctx.write(map[string]interface{}{
+		"type":     "file",
+		"path":     rel,
+		"size":     len(data),
+		"mime":     http.DetectContentType(data),
+		"encoding": "base64",
+		"data":     base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+// sendFileManifest 는 실행 전 스냅샷과 비교해서 새로 생기거나 바뀐 파일을 알린다.
+// 클라이언트가 get_file 을 따로 보내지 않아도 되도록 작은 파일은 내용을 함께 보낸다.
+func sendFileManifest(ctx *ConnectionContext, before map[string]WorkspaceEntry) {
+	after, err := listWorkspace(ctx.box, ctx.hiddenFiles())
+	if err != nil {
+		return
+	}
+
+	files := []map[string]interface{}{}
+	inlined := 0
+	for _, entry := range sortedEntries(after) {
+		status := "created"
+		if prev, ok := before[entry.Path]; ok {
+			if prev.Size == entry.Size && prev.Modified.Equal(entry.Modified) {
+				continue
+			}
+			status = "modified"
+		}
+		if len(files) >= manifestMaxFiles {
+			break
+		}
+
+		file := map[string]interface{}{
+			"path":   entry.Path,
+			"size":   entry.Size,
+			"status": status,
+		}
+		if entry.Size <= manifestInlineLimit && inlined+int(entry.Size) <= manifestTotalLimit {
+			if data, err := ctx.box.readFile(filepath.Join(ctx.box.Workspace, entry.Path), manifestInlineLimit); err == nil {
+				inlined += len(data)
+				file["mime"] = http.DetectContentType(data)
+				file["encoding"] = "base64"
+				file["data"] = base64.StdEncoding.EncodeToString(data)
+			}
+		}
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return
+	}
+	ctx.write(map[string]interface{}{
+		"type":  "files",
+		"files": files,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveTestConnection 은 isolate 없이 임시 workspace 의 box 로 serveConnection 을 띄우고
+// 클라이언트 연결과 서버 쪽 ctx 를 돌려준다.
+func serveTestConnection(t *testing.T) (*websocket.Conn, *ConnectionContext) {
+	t.Helper()
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	contexts := make(chan *ConnectionContext, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ctx := &ConnectionContext{conn: conn, box: box}
+		contexts <- ctx
+		serveConnection(ctx)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, <-contexts
+}
+
+func readEvent(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event map[string]interface{}
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestGetFileAfterExit(t *testing.T) {
+	timeout := exitIdleTimeout
+	exitIdleTimeout = 300 * time.Millisecond
+	defer func() { exitIdleTimeout = timeout }()
+
+	client, ctx := serveTestConnection(t)
+	name := "result.txt"
+	if err := os.WriteFile(filepath.Join(ctx.box.Workspace, name), []byte("result"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(ctx.box.Workspace, "link.txt")
+	if err := os.Symlink("/etc/hostname", link); err != nil {
+		t.Fatal(err)
+	}
+	ctx.finishRun()
+
+	// 종료 뒤에도 메시지를 보낼 때마다 idle timeout 이 다시 걸린다.
+	for i := 0; i < 3; i++ {
+		time.Sleep(150 * time.Millisecond)
+		if err := client.WriteJSON(Message{Type: "get_file", Path: name}); err != nil {
+			t.Fatal(err)
+		}
+		event := readEvent(t, client)
+		if event["type"] != "file" {
+			t.Fatalf("event = %v, want file", event)
+		}
+		data, _ := base64.StdEncoding.DecodeString(event["data"].(string))
+		if string(data) != "result" {
+			t.Fatalf("data = %q", data)
+		}
+	}
+
+	if err := client.WriteJSON(Message{Type: "get_file", Path: filepath.Base(link)}); err != nil {
+		t.Fatal(err)
+	}
+	if event := readEvent(t, client); event["type"] != "file_error" || event["error"] != "not a regular file" {
+		t.Fatalf("symlink event = %v, want file_error", event)
+	}
+
+	// 아무 메시지도 없으면 idle timeout 뒤에 서버가 연결을 닫는다.
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("connection is still open after the idle timeout")
+	} else if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+		t.Fatal("server did not close the connection")
+	}
+}
+
+func TestConnectionStaysOpenWhileRunning(t *testing.T) {
+	timeout := exitIdleTimeout
+	exitIdleTimeout = 100 * time.Millisecond
+	defer func() { exitIdleTimeout = timeout }()
+
+	client, ctx := serveTestConnection(t)
+	ctx.finishRun()
+	ctx.startRun()
+
+	time.Sleep(300 * time.Millisecond)
+	if err := client.WriteJSON(Message{Type: "list_files"}); err != nil {
+		t.Fatal(err)
+	}
+	if event := readEvent(t, client); event["type"] != "file_list" && event["type"] != "file_error" {
+		t.Fatalf("event = %v", event)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// 포매터는 사용자 코드 실행과 섞이지 않도록 별도의 isolate box 에서 실행한다.
+const (
+	formatBoxID       = "1"
+	formatSourceLimit = 256 * 1024
+	formatOutputLimit = 1024 * 1024
+)
+
+var formatMu sync.Mutex
+
+const (
+	FormatErrUnsupported = "unsupported_language"
+	FormatErrTooLarge    = "source_too_large"
+	FormatErrFailed      = "formatter_failed"
+	FormatErrInternal    = "internal_error"
+)
+
+type FormatError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"error"`
+	Stderr  string `json:"stderr,omitempty"`
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+type formatRequest struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+func formatSource(language, source string) (string, *FormatError) {
+	option, ok := CompileOptions[language]
+	if !ok || len(option.FormatCmd) == 0 {
+		return "", &FormatError{
+			Kind:    FormatErrUnsupported,
+			Message: fmt.Sprintf("no formatter for language: %s", language),
+		}
+	}
+	if len(source) > formatSourceLimit {
+		return "", &FormatError{
+			Kind:    FormatErrTooLarge,
+			Message: fmt.Sprintf("source exceeds %d bytes", formatSourceLimit),
+		}
+	}
+
+	formatMu.Lock()
+	defer formatMu.Unlock()
+
+	if err := runIsolateBoxCommand(formatBoxID, "--init"); err != nil {
+		return "", &FormatError{Kind: FormatErrInternal, Message: fmt.Sprintf("failed to init isolate: %v", err)}
+	}
+	defer func() {
+		if err := runIsolateBoxCommand(formatBoxID, "--cleanup"); err != nil {
+			log.Println("format isolate cleanup error:", err)
+		}
+	}()
+
+	args := []string{
+		"--box-id=" + formatBoxID,
+		"--dir=/usr/bin",
+		// google-java-format 처럼 /opt 에 설치한 포매터가 있다.
+		"--dir=/opt",
+		"--silent",
+		"--processes",
+		"--time=5",
+		"--wall-time=10",
+		"--env=HOME=/box",
+		"--run", "--",
+	}
+	args = append(args, option.FormatCmd...)
+
+	var stdout, stderr limitedBuffer
+	stdout.limit = formatOutputLimit
+	stderr.limit = formatOutputLimit
+
+	cmd := exec.Command(isolateBinary, args...)
+	cmd.Stdin = strings.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", &FormatError{
+			Kind:    FormatErrFailed,
+			Message: fmt.Sprintf("formatter exited: %v", err),
+			Stderr:  stderr.String(),
+		}
+	}
+	if stdout.truncated {
+		return "", &FormatError{
+			Kind:    FormatErrFailed,
+			Message: fmt.Sprintf("formatted output exceeds %d bytes", formatOutputLimit),
+		}
+	}
+
+	return stdout.String(), nil
+}
+
+func handleFormat(ctx *ConnectionContext, msg *Message) {
+	formatted, formatErr := formatSource(msg.Language, msg.Source)
+	if formatErr != nil {
+		ctx.write(map[string]interface{}{
+			"type":   "format_error",
+			"kind":   formatErr.Kind,
+			"error":  formatErr.Message,
+			"stderr": formatErr.Stderr,
+		})
+		return
+	}
+
+	ctx.write(map[string]interface{}{
+		"type":   "format_result",
+		"source": formatted,
+	})
+}
+
+func formatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req formatRequest
+	body := http.MaxBytesReader(w, r.Body, formatSourceLimit*2)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &FormatError{
+			Kind:    FormatErrInternal,
+			Message: fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+
+	formatted, formatErr := formatSource(req.Language, req.Source)
+	if formatErr != nil {
+		status := http.StatusUnprocessableEntity
+		if formatErr.Kind == FormatErrInternal {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, formatErr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"source": formatted})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("writeJSON error:", err)
+	}
+}
+
+// 출력이 너무 큰 경우 limit 까지만 저장하고 나머지는 버린다.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.Buffer.Len()
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.truncated = true
+		b.Buffer.Write(p[:remaining])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
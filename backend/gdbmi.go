@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GDB/MI 출력 한 줄. https://sourceware.org/gdb/current/onlinedocs/gdb.html/GDB_002fMI-Output-Syntax.html
+type miRecord struct {
+	Token   int
+	Kind    byte // '^' result, '*' exec async, '+' status async, '=' notify, '~' '@' '&' stream
+	Class   string
+	Results map[string]interface{}
+	Stream  string
+}
+
+func parseMIRecord(line string) (*miRecord, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" || line == "(gdb)" || line == "(gdb) " {
+		return nil, nil
+	}
+
+	i := 0
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	record := &miRecord{Token: -1}
+	if i > 0 {
+		record.Token, _ = strconv.Atoi(line[:i])
+	}
+	if i >= len(line) {
+		return nil, fmt.Errorf("truncated MI record: %q", line)
+	}
+
+	record.Kind = line[i]
+	rest := line[i+1:]
+
+	switch record.Kind {
+	case '~', '@', '&':
+		p := &miParser{s: rest}
+		text, err := p.cstring()
+		if err != nil {
+			return nil, err
+		}
+		record.Stream = text
+		return record, nil
+
+	case '^', '*', '+', '=':
+		comma := strings.IndexByte(rest, ',')
+		if comma < 0 {
+			record.Class = rest
+			record.Results = map[string]interface{}{}
+			return record, nil
+		}
+		record.Class = rest[:comma]
+		p := &miParser{s: rest[comma+1:]}
+		results, err := p.results("")
+		if err != nil {
+			return nil, err
+		}
+		record.Results = results
+		return record, nil
+	}
+
+	return nil, fmt.Errorf("unknown MI record: %q", line)
+}
+
+type miParser struct {
+	s   string
+	pos int
+}
+
+func (p *miParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// results 는 terminator 가 나오거나 입력이 끝날 때까지 var=value 목록을 읽는다.
+func (p *miParser) results(terminator string) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	for p.pos < len(p.s) {
+		if terminator != "" && strings.HasPrefix(p.s[p.pos:], terminator) {
+			break
+		}
+		name, value, err := p.result()
+		if err != nil {
+			return nil, err
+		}
+		results[name] = value
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+	return results, nil
+}
+
+func (p *miParser) result() (string, interface{}, error) {
+	eq := strings.IndexByte(p.s[p.pos:], '=')
+	if eq < 0 {
+		return "", nil, fmt.Errorf("expected '=' at %d in %q", p.pos, p.s)
+	}
+	name := p.s[p.pos : p.pos+eq]
+	p.pos += eq + 1
+	value, err := p.value()
+	return name, value, err
+}
+
+func (p *miParser) value() (interface{}, error) {
+	switch p.peek() {
+	case '"':
+		return p.cstring()
+	case '{':
+		p.pos++
+		tuple, err := p.results("}")
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != '}' {
+			return nil, fmt.Errorf("unterminated tuple in %q", p.s)
+		}
+		p.pos++
+		return tuple, nil
+	case '[':
+		p.pos++
+		list := []interface{}{}
+		for p.peek() != ']' {
+			if p.pos >= len(p.s) {
+				return nil, fmt.Errorf("unterminated list in %q", p.s)
+			}
+			// list 는 값 목록이거나 var=value 목록이다. 후자는 이름을 버린다.
+			var item interface{}
+			var err error
+			if c := p.peek(); c == '"' || c == '{' || c == '[' {
+				item, err = p.value()
+			} else {
+				_, item, err = p.result()
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if p.peek() == ',' {
+				p.pos++
+			}
+		}
+		p.pos++
+		return list, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d in %q", p.peek(), p.pos, p.s)
+}
+
+func (p *miParser) cstring() (string, error) {
+	if p.peek() != '"' {
+		return "", fmt.Errorf("expected string at %d in %q", p.pos, p.s)
+	}
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos >= len(p.s) {
+				return "", fmt.Errorf("unterminated escape in %q", p.s)
+			}
+			e := p.s[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				end := p.pos - 1
+				for end < len(p.s) && end < p.pos+2 && p.s[end] >= '0' && p.s[end] <= '7' {
+					end++
+				}
+				n, _ := strconv.ParseUint(p.s[p.pos-1:end], 8, 8)
+				b.WriteByte(byte(n))
+				p.pos = end
+			default:
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string in %q", p.s)
+}
+
+// miQuote 는 MI 명령 인자로 넣을 수 있도록 문자열을 c-string 으로 만든다.
+func miQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func miString(m map[string]interface{}, key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func miTuple(m map[string]interface{}, key string) map[string]interface{} {
+	if v, ok := m[key].(map[string]interface{}); ok {
+		return v
+	}
+	return map[string]interface{}{}
+}
+
+func miList(m map[string]interface{}, key string) []interface{} {
+	if v, ok := m[key].([]interface{}); ok {
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMIRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *miRecord
+	}{
+		{
+			name: "prompt",
+			line: "(gdb) ",
+			want: nil,
+		},
+		{
+			name: "result without results",
+			line: "12^done",
+			want: &miRecord{Token: 12, Kind: '^', Class: "done", Results: map[string]interface{}{}},
+		},
+		{
+			name: "stopped at breakpoint",
+			line: `*stopped,reason="breakpoint-hit",bkptno="1",frame={addr="0x0000555555555131",func="main",args=[],file="main.c",line="4"},thread-id="1"`,
+			want: &miRecord{Token: -1, Kind: '*', Class: "stopped", Results: map[string]interface{}{
+				"reason": "breakpoint-hit",
+				"bkptno": "1",
+				"frame": map[string]interface{}{
+					"addr": "0x0000555555555131",
+					"func": "main",
+					"args": []interface{}{},
+					"file": "main.c",
+					"line": "4",
+				},
+				"thread-id": "1",
+			}},
+		},
+		{
+			name: "list of named tuples",
+			line: `3^done,stack=[frame={level="0",func="f"},frame={level="1",func="main"}]`,
+			want: &miRecord{Token: 3, Kind: '^', Class: "done", Results: map[string]interface{}{
+				"stack": []interface{}{
+					map[string]interface{}{"level": "0", "func": "f"},
+					map[string]interface{}{"level": "1", "func": "main"},
+				},
+			}},
+		},
+		{
+			name: "list of values",
+			line: `^done,names=["a","b"]`,
+			want: &miRecord{Token: -1, Kind: '^', Class: "done", Results: map[string]interface{}{
+				"names": []interface{}{"a", "b"},
+			}},
+		},
+		{
+			name: "console stream with escapes",
+			line: `~"hello \"world\"\n\tx\101"`,
+			want: &miRecord{Token: -1, Kind: '~', Stream: "hello \"world\"\n\txA"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMIRecord(tt.line)
+			if err != nil {
+				t.Fatalf("parseMIRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMIRecord() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMIRecordErrors(t *testing.T) {
+	for _, line := range []string{
+		"123",
+		`~"unterminated`,
+		`^done,frame={addr="0x1"`,
+		`^done,list=["a"`,
+		`^done,novalue`,
+		`?unknown`,
+	} {
+		if record, err := parseMIRecord(line); err == nil {
+			t.Errorf("parseMIRecord(%q) = %+v, want error", line, record)
+		}
+	}
+}
+
+func TestMIQuote(t *testing.T) {
+	for _, s := range []string{"plain", `with "quotes"`, `back\slash`, "new\nline"} {
+		p := &miParser{s: miQuote(s)}
+		got, err := p.cstring()
+		if err != nil {
+			t.Fatalf("cstring(miQuote(%q)) error = %v", s, err)
+		}
+		if got != s {
+			t.Errorf("cstring(miQuote(%q)) = %q", s, got)
+		}
+	}
+}
@@ -4,4 +4,7 @@ go 1.22.0
 
 toolchain go1.22.3
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/sys v0.26.0
+)
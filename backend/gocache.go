@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 이미지 빌드 때 표준 라이브러리를 미리 컴파일해 둔 GOCACHE 를 읽기 전용으로 두고,
+// 실행마다 workspace 안에 하드 링크로 펼쳐서 쓴다. 새로 생기는 항목은 workspace 쪽에만 쓰이고
+// workspace 를 초기화할 때 같이 지워진다. go 는 캐시 항목을 새 파일로만 만들기 때문에
+// 하드 링크를 공유해도 원본 내용은 바뀌지 않는다.
+const (
+	goCacheBase = "/opt/iris/gocache"
+	goCacheDir  = "/code/.cache/go-build"
+	// go 가 마지막으로 캐시를 정리한 시각을 덮어쓰는 파일이라 공유하지 않는다.
+	goCacheTrimFile = "trim.txt"
+)
+
+// configureGoCache 는 러너가 직접 실행하는 go 명령들이 실행마다의 캐시를 쓰게 한다.
+// 컴파일 명령은 box 의 workspace 가 /code 로 보이는 namespace 에서 실행된다.
+func configureGoCache() {
+	_ = os.Setenv("GOCACHE", goCacheDir)
+}
+
+// prepareGoCache 는 미리 만든 캐시를 workspace 로 펼친다. 캐시가 없는 이미지에서는
+// go 가 빈 캐시로 시작하므로 오류로 보지 않는다.
+func prepareGoCache(box *Box) error {
+	if _, err := os.Stat(goCacheBase); os.IsNotExist(err) {
+		return nil
+	}
+	return copyGoCache(goCacheBase, box.path(goCacheDir))
+}
+
+func copyGoCache(base string, dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+
+	// 같은 파일 시스템이 아니면 하드 링크를 만들 수 없으므로 복사한다.
+	output, err := exec.Command("/bin/cp", "-al", base, dir).CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		output, err = exec.Command("/bin/cp", "-a", base, dir).CombinedOutput()
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	// 원본은 읽기 전용이라 디렉터리도 쓸 수 없게 복사된다.
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return os.Chmod(path, 0o755)
+	})
+	if err != nil {
+		return err
+	}
+
+	trim := filepath.Join(dir, goCacheTrimFile)
+	_ = os.Remove(trim)
+	return os.WriteFile(trim, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0o644)
+}
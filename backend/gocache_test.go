@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyGoCache(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "gocache")
+	if err := os.MkdirAll(filepath.Join(base, "ab"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "ab", "ab12-d"), []byte("object"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, goCacheTrimFile), []byte("0"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	// 이미지의 원본처럼 디렉터리도 읽기 전용으로 둔다.
+	for _, dir := range []string{filepath.Join(base, "ab"), base} {
+		if err := os.Chmod(dir, 0o555); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(dir, 0o755)
+	}
+
+	dir := filepath.Join(t.TempDir(), "code", ".cache", "go-build")
+	if err := copyGoCache(base, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "ab", "ab12-d")); err != nil || string(data) != "object" {
+		t.Fatalf("cache entry = %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("directory mode = %v, want 0755", info.Mode().Perm())
+	}
+	// trim.txt 는 새로 써도 원본에 영향이 없어야 한다.
+	if data, _ := os.ReadFile(filepath.Join(base, goCacheTrimFile)); string(data) != "0" {
+		t.Errorf("base trim.txt = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, goCacheTrimFile)); string(data) == "0" {
+		t.Error("trim.txt was not replaced")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// /healthz 는 프로세스가 살아 있는지만 보는 liveness 용이고, /readyz 는 실제로 코드를 실행할 수 있는지 본다.
+// readiness 검사는 isolate 로 box 를 만들고 명령 하나를 실행한 뒤 지우는 왕복과 언어별 실행 파일 확인이다.
+// 프로브가 자주 오므로 검사는 백그라운드에서 주기적으로 돌리고 핸들러는 마지막 결과만 돌려준다.
+// 시작 self-test(selftest.go)가 끝나기 전에는 준비되지 않은 것으로 본다.
+// 언어 하나가 깨진 것은 그 언어만 disabled 로 표시하고, isolate 가 동작하지 않거나 쓸 수 있는 언어가
+// 하나도 없을 때만 pod 을 준비되지 않은 것으로 본다.
+const (
+	// 세션 box(2 번부터)나 포매터 box(1)와 겹치지 않도록 0 번 box 를 쓴다. 시작 self-test 도 이 box 를 쓴다.
+	readinessBoxID      = 0
+	readinessInterval   = 30 * time.Second
+	readinessCmdTimeout = 10 * time.Second
+)
+
+type readinessCheck struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+
+	// 언어 검사에만 있는 값
+	Disabled   bool   `json:"disabled,omitempty"`
+	Version    string `json:"version,omitempty"`
+	SelfTestMs int64  `json:"selftest_ms,omitempty"`
+}
+
+type readinessReport struct {
+	Ready     bool             `json:"ready"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Checks    []readinessCheck `json:"checks"`
+}
+
+var readiness struct {
+	mu     sync.Mutex
+	report *readinessReport
+}
+
+func startReadinessChecks() {
+	go func() {
+		runSelfTests()
+		for {
+			report := checkReadiness()
+			readiness.mu.Lock()
+			readiness.report = &report
+			readiness.mu.Unlock()
+			time.Sleep(readinessInterval)
+		}
+	}()
+}
+
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	readiness.mu.Lock()
+	report := readiness.report
+	readiness.mu.Unlock()
+
+	if report == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"ready":  false,
+			"checks": []readinessCheck{},
+		})
+		return
+	}
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func checkReadiness() readinessReport {
+	report := readinessReport{Ready: true, CheckedAt: time.Now()}
+
+	start := time.Now()
+	err := checkIsolateRoundTrip()
+	isolate := readinessCheck{Name: "isolate", OK: err == nil, DurationMs: elapsedMs(start)}
+	if err != nil {
+		isolate.Detail = err.Error()
+		report.Ready = false
+	}
+	report.Checks = append(report.Checks, isolate)
+
+	names := make([]string, 0, len(CompileOptions))
+	for name := range CompileOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	disabled := map[string]string{}
+	enabled := 0
+	for _, name := range names {
+		check := checkLanguage(name)
+		if check.Disabled {
+			disabled[name] = check.Detail
+		} else if _, ok := selfTestPrograms[name]; ok {
+			enabled++
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	setDisabledLanguages(disabled)
+
+	if enabled == 0 {
+		report.Ready = false
+	}
+	return report
+}
+
+// checkLanguage 는 실행 파일이 있는지 보고 시작 self-test 결과를 붙인다. 둘 중 하나라도 실패하면 disabled 다.
+func checkLanguage(name string) readinessCheck {
+	start := time.Now()
+	check := readinessCheck{Name: "language:" + name, OK: true}
+	if err := checkBinaries(languageBinaries(CompileOptions[name])); err != nil {
+		check.OK = false
+		check.Detail = err.Error()
+	}
+	if result, ok := languageSelfTest(name); ok {
+		check.Version = result.Version
+		check.SelfTestMs = result.LatencyMs
+		if !result.OK && check.OK {
+			check.OK = false
+			check.Detail = "self-test failed: " + result.Error
+		}
+	}
+	check.Disabled = !check.OK
+	check.DurationMs = elapsedMs(start)
+	return check
+}
+
+// checkIsolateRoundTrip 은 세션이 쓰는 것과 같은 방법으로 box 를 만들고 실행하고 지운다.
+func checkIsolateRoundTrip() error {
+	ctx, cancel := context.WithTimeout(context.Background(), readinessCmdTimeout)
+	defer cancel()
+
+	run := func(args ...string) error {
+		args = append([]string{"--box-id=" + strconv.Itoa(readinessBoxID)}, args...)
+		output, err := exec.CommandContext(ctx, isolateBinary, args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("isolate %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	_ = run("--cleanup")
+	if err := run("--init"); err != nil {
+		return err
+	}
+	runErr := run("--time=5", "--wall-time=5", "--run", "--", "/bin/true")
+	if err := run("--cleanup"); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+// languageBinaries 는 언어가 쓰는 명령의 실행 파일을 모은다. workspace 안에서 만들어지는 파일은 뺀다.
+func languageBinaries(option CompileOption) []string {
+	commands := [][]string{option.CompileCmd, option.ExecuteCmd, option.DebugCompileCmd, option.TraceCmd, option.ReplCmd}
+	if option.Test != nil {
+		commands = append(commands, option.Test.CompileCmd, option.Test.ExecuteCmd)
+	}
+	if option.Coverage != nil {
+		commands = append(commands, option.Coverage.CompileCmd, option.Coverage.ExecuteCmd, option.Coverage.CollectCmd)
+	}
+
+	seen := map[string]bool{}
+	var binaries []string
+	for _, cmd := range commands {
+		if len(cmd) == 0 {
+			continue
+		}
+		binary := cmd[0]
+		if seen[binary] || strings.HasPrefix(binary, workspaceDir+"/") {
+			continue
+		}
+		seen[binary] = true
+		binaries = append(binaries, binary)
+	}
+	return binaries
+}
+
+func checkBinaries(binaries []string) error {
+	var missing []string
+	for _, binary := range binaries {
+		info, err := os.Stat(binary)
+		if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+			missing = append(missing, binary)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing or not executable: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLanguageBinaries(t *testing.T) {
+	option := CompileOption{
+		CompileCmd: []string{"/usr/bin/gcc", "-o", workspaceDir + "/main", workspaceDir + "/main.c"},
+		ExecuteCmd: []string{workspaceDir + "/main"},
+		TraceCmd:   []string{"/usr/bin/gcc", "-g"},
+		Test: &TestOption{
+			CompileCmd: []string{"/usr/bin/g++"},
+		},
+	}
+	want := []string{"/usr/bin/gcc", "/usr/bin/g++"}
+	if got := languageBinaries(option); !reflect.DeepEqual(got, want) {
+		t.Errorf("languageBinaries() = %v, want %v", got, want)
+	}
+	if got := languageBinaries(CompileOption{}); len(got) != 0 {
+		t.Errorf("languageBinaries(empty) = %v", got)
+	}
+}
+
+func TestCheckBinaries(t *testing.T) {
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "tool")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "data")
+	if err := os.WriteFile(plain, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkBinaries([]string{executable}); err != nil {
+		t.Errorf("checkBinaries(executable) = %v", err)
+	}
+	err := checkBinaries([]string{executable, plain, dir, filepath.Join(dir, "missing")})
+	want := "missing or not executable: " + strings.Join([]string{plain, dir, filepath.Join(dir, "missing")}, ", ")
+	if err == nil || err.Error() != want {
+		t.Errorf("checkBinaries() = %v, want %q", err, want)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	readiness.mu.Lock()
+	original := readiness.report
+	readiness.mu.Unlock()
+	defer func() {
+		readiness.mu.Lock()
+		readiness.report = original
+		readiness.mu.Unlock()
+	}()
+
+	get := func() int {
+		recorder := httptest.NewRecorder()
+		readyHandler(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		return recorder.Code
+	}
+
+	// 첫 검사가 끝나기 전에는 준비되지 않은 것으로 본다.
+	readiness.report = nil
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("before the first check: %d", code)
+	}
+	readiness.report = &readinessReport{Ready: true}
+	if code := get(); code != http.StatusOK {
+		t.Errorf("ready: %d", code)
+	}
+	readiness.report = &readinessReport{Ready: false}
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("not ready: %d", code)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 프로그램이 imageDir 에 쓴 그림 파일을 image 이벤트로 보낸다.
+// matplotlib 은 iris_mpl 백엔드가 plt.show() 때, turtle 은 tools/python/turtle.py 가 done() 이나
+// 프로그램 종료 때 그림을 이 디렉터리에 저장한다.
+const (
+	imageDir          = "/code/.iris-images"
+	imagePollInterval = 200 * time.Millisecond
+	imageMaxSize      = 2 * 1024 * 1024
+	imageMaxTotal     = 8 * 1024 * 1024
+	imageMaxCount     = 20
+)
+
+var imageMimeTypes = map[string]string{
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+}
+
+var imageIsolateArgs = []string{
+	"--env=IRIS_IMAGE_DIR=" + imageDir,
+	"--env=MPLBACKEND=module://iris_mpl",
+	"--env=MPLCONFIGDIR=/tmp/matplotlib",
+	"--env=PYTHONPATH=/usr/local/lib/iris/python",
+}
+
+type imageWatcher struct {
+	ctx  *ConnectionContext
+	dir  string
+	stop chan struct{}
+	done chan struct{}
+
+	// 파일 이름별로 마지막으로 보낸(또는 거절한) 상태. 다시 쓰인 파일은 또 보낸다.
+	sent    map[string]imageState
+	pending map[string]imageState
+	count   int
+	total   int64
+	limited bool
+}
+
+type imageState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s imageState) same(other imageState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+func startImageWatcher(ctx *ConnectionContext) (*imageWatcher, error) {
+	// 이전 실행이 남긴 것이 디렉터리가 아니면(symlink 등) 지우고 새로 만든다.
+	dir := ctx.box.path(imageDir)
+	if info, err := os.Lstat(dir); err == nil && !info.IsDir() {
+		if err := ctx.box.removeAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to create image directory: %w", err)
+		}
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if err := ctx.box.chown(dir); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	return watchImages(ctx, dir), nil
+}
+
+func watchImages(ctx *ConnectionContext, dir string) *imageWatcher {
+	w := &imageWatcher{
+		ctx:     ctx,
+		dir:     dir,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		sent:    map[string]imageState{},
+		pending: map[string]imageState{},
+	}
+	go w.run()
+	return w
+}
+
+func (w *imageWatcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(imagePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.scan(false)
+		}
+	}
+}
+
+// finish 는 감시를 멈추고 남은 파일을 모두 보낸다. 프로세스가 끝난 뒤에 호출한다.
+func (w *imageWatcher) finish() {
+	w.close()
+	w.scan(true)
+}
+
+func (w *imageWatcher) close() {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+}
+
+// 쓰는 중인 파일을 보내지 않도록 두 번 연속 같은 크기와 시간으로 보일 때 보낸다.
+// final 이면 프로세스가 끝났으므로 바로 보낸다.
+func (w *imageWatcher) scan(final bool) {
+	// 프로그램이 디렉터리를 symlink 로 바꿔 놓았을 수 있으므로 openBeneath 로 연다.
+	dir, err := openBeneath(filepath.Dir(w.dir), w.dir, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return
+	}
+	entries, err := dir.ReadDir(-1)
+	dir.Close()
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		name := entry.Name()
+		mime, ok := imageMimeTypes[strings.ToLower(filepath.Ext(name))]
+		if !ok || strings.HasPrefix(name, ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		state := imageState{size: info.Size(), modTime: info.ModTime()}
+		if prev, ok := w.sent[name]; ok && prev.same(state) {
+			continue
+		}
+		if prev, ok := w.pending[name]; !final && (!ok || !prev.same(state)) {
+			w.pending[name] = state
+			continue
+		}
+		delete(w.pending, name)
+		w.sent[name] = state
+		w.send(name, mime, state.size)
+	}
+}
+
+func (w *imageWatcher) send(name string, mime string, size int64) {
+	if w.count >= imageMaxCount || w.total+size > imageMaxTotal {
+		// 한도를 넘은 뒤에는 한 번만 알린다.
+		if !w.limited {
+			w.limited = true
+			w.writeError(name, fmt.Sprintf("image limit reached (%d images, %d bytes)", imageMaxCount, imageMaxTotal))
+		}
+		return
+	}
+	if size > imageMaxSize {
+		w.writeError(name, fmt.Sprintf("image exceeds %d bytes", imageMaxSize))
+		return
+	}
+
+	data, err := readFileBeneath(w.dir, filepath.Join(w.dir, name), imageMaxSize)
+	if errors.Is(err, errFileTooLarge) {
+		w.writeError(name, fmt.Sprintf("image exceeds %d bytes", imageMaxSize))
+		return
+	}
+	if err != nil {
+		w.writeError(name, fmt.Sprintf("failed to read image: %v", err))
+		return
+	}
+
+	w.count++
+	w.total += int64(len(data))
+	w.ctx.write(map[string]interface{}{
+		"type":     "image",
+		"name":     name,
+		"mime":     mime,
+		"size":     len(data),
+		"encoding": "base64",
+		"data":     base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+func (w *imageWatcher) writeError(name string, message string) {
+	w.ctx.write(map[string]interface{}{
+		"type":  "image_error",
+		"name":  name,
+		"error": message,
+	})
+}
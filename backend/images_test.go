@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageWatcherSendsImages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "images")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "secret.png")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plot.png"), []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 쓰는 중인 파일과 이미지가 아닌 파일, symlink 는 보내지 않는다.
+	if err := os.WriteFile(filepath.Join(dir, ".plot.png.part"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	client, ctx := serveTestConnection(t)
+	w := watchImages(ctx, dir)
+	w.finish()
+
+	event := readEvent(t, client)
+	if event["type"] != "image" || event["name"] != "plot.png" || event["mime"] != "image/png" {
+		t.Fatalf("event = %v", event)
+	}
+	data, _ := base64.StdEncoding.DecodeString(event["data"].(string))
+	if string(data) != "png" {
+		t.Fatalf("data = %q", data)
+	}
+
+	// 다시 쓰인 파일은 또 보내고, 바뀌지 않은 파일은 보내지 않는다.
+	if err := os.WriteFile(filepath.Join(dir, "plot.png"), []byte("png2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.scan(true)
+	event = readEvent(t, client)
+	if event["type"] != "image" || event["name"] != "plot.png" || event["size"] != float64(4) {
+		t.Fatalf("event = %v", event)
+	}
+	w.scan(true)
+	ctx.write(map[string]interface{}{"type": "marker"})
+	if event := readEvent(t, client); event["type"] != "marker" {
+		t.Fatalf("event = %v, want marker", event)
+	}
+}
+
+func TestImageWatcherRejectsSymlinkedDirectory(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.png"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "images")); err != nil {
+		t.Fatal(err)
+	}
+
+	client, ctx := serveTestConnection(t)
+	w := watchImages(ctx, filepath.Join(root, "images"))
+	w.finish()
+
+	ctx.write(map[string]interface{}{"type": "marker"})
+	if event := readEvent(t, client); event["type"] != "marker" {
+		t.Fatalf("event = %v, want marker", event)
+	}
+}
+
+func TestStartImageWatcherReplacesSymlink(t *testing.T) {
+	client, ctx := serveTestConnection(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.png"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := ctx.box.path(imageDir)
+	if err := os.Symlink(outside, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := startImageWatcher(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("%s is not a directory: %v", imageDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plot.svg"), []byte("<svg/>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w.finish()
+
+	event := readEvent(t, client)
+	if event["type"] != "image" || event["name"] != "plot.svg" || event["mime"] != "image/svg+xml" {
+		t.Fatalf("event = %v", event)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "secret.png")); err != nil {
+		t.Errorf("file behind the symlink was removed: %v", err)
+	}
+}
+
+func TestTurtleSavesSVG(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	dir := t.TempDir()
+	program := `
+import turtle
+t = turtle.Turtle()
+t.color("red", "yellow")
+t.begin_fill()
+for _ in range(4):
+    t.forward(50)
+    t.left(90)
+t.end_fill()
+turtle.circle(20)
+turtle.write("hello")
+`
+	cmd := exec.Command(python, "-c", program)
+	cmd.Env = append(os.Environ(), "IRIS_IMAGE_DIR="+dir, "PYTHONDONTWRITEBYTECODE=1", "PYTHONPATH="+filepath.Join("tools", "python"))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, output)
+	}
+
+	// done() 을 부르지 않아도 프로그램이 끝날 때 저장한다.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || filepath.Ext(entries[0].Name()) != ".svg" {
+		t.Fatalf("entries = %v", entries)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+	for _, want := range []string{"<svg", "<polygon", `fill="yellow"`, `stroke="red"`, ">hello</text>"} {
+		if !strings.Contains(svg, want) {
+			t.Fatalf("svg does not contain %q:\n%s", want, svg)
+		}
+	}
+}
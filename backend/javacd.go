@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Java 는 javac 와 java 두 번 JVM 을 띄우므로 시작 비용이 크다. 두 가지를 선택적으로 켤 수 있다.
+// IRIS_JAVAC_DAEMON=1: tools/JavacDaemon.java 를 띄워 두고 javax.tools 로 컴파일한다.
+// 데몬은 샌드박스 밖에서 컴파일만 하고, 사용자 코드는 여전히 isolate 안의 java 로 실행된다.
+// IRIS_JAVA_CDS=1: 이미지에 만들어 둔 JDK 클래스 CDS 아카이브로 java 를 시작한다.
+const (
+	javaToolsDir        = "/usr/local/lib/iris/java"
+	javaCDSArchive      = javaToolsDir + "/jdk.jsa"
+	javacDaemonTimeout  = 30 * time.Second
+	javacDaemonMaxUses  = 200
+	javacDaemonHeapArgs = "-Xmx128m"
+)
+
+var (
+	javacDaemonEnabled = os.Getenv("IRIS_JAVAC_DAEMON") == "1"
+	javaCDSEnabled     = os.Getenv("IRIS_JAVA_CDS") == "1"
+)
+
+var javacd = &javacDaemon{}
+
+type javacDaemon struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	// 메모리가 계속 늘지 않도록 일정 횟수 컴파일한 뒤에는 새로 띄운다.
+	uses int
+}
+
+// warmJavacDaemon 은 첫 실행이 JVM 시작을 기다리지 않도록 미리 띄워서 컴파일러를 적재한다.
+func warmJavacDaemon() {
+	if !javacDaemonEnabled {
+		return
+	}
+	go func() {
+		if _, _, err := javacd.compile([]string{"-version"}); err != nil {
+			log.Println("javac daemon warm-up error:", err)
+		}
+	}()
+}
+
+// compileJava 는 데몬으로 컴파일하고, 데몬이 응답하지 않으면 javac 프로세스로 다시 컴파일한다.
+// 두 번째 값은 실제로 컴파일한 쪽 이름이다.
+// 데몬은 box 의 mount namespace 밖에 있으므로 경로를 호스트 경로로 바꿔서 넘기고 출력은 되돌린다.
+func compileJava(box *Box, compileCmd []string) (string, string, error) {
+	if javacDaemonEnabled {
+		args := make([]string, 0, len(compileCmd)-1)
+		for _, arg := range compileCmd[1:] {
+			args = append(args, box.path(arg))
+		}
+		output, code, err := javacd.compile(args)
+		output = strings.ReplaceAll(output, box.Workspace, workspaceDir)
+		if err == nil {
+			if code != 0 {
+				return output, "javac-daemon", fmt.Errorf("javac exited with status %d", code)
+			}
+			return output, "javac-daemon", nil
+		}
+		log.Println("javac daemon error:", err)
+	}
+	output, err := box.runCommand(compileCmd)
+	return output, "javac", err
+}
+
+func (d *javacDaemon) compile(args []string) (string, int, error) {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "\t\n") {
+			return "", 0, fmt.Errorf("unsupported javac argument %q", arg)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cmd == nil || d.uses >= javacDaemonMaxUses {
+		d.stop()
+		if err := d.start(); err != nil {
+			return "", 0, err
+		}
+	}
+	d.uses++
+
+	type response struct {
+		output string
+		code   int
+		err    error
+	}
+	done := make(chan response, 1)
+	stdin, stdout := d.stdin, d.stdout
+	go func() {
+		output, code, err := javacRoundTrip(stdin, stdout, args)
+		done <- response{output, code, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			d.stop()
+		}
+		return r.output, r.code, r.err
+	case <-time.After(javacDaemonTimeout):
+		// 프로세스를 죽이면 읽던 고루틴도 EOF 로 끝난다.
+		d.stop()
+		return "", 0, fmt.Errorf("javac daemon timed out after %v", javacDaemonTimeout)
+	}
+}
+
+func (d *javacDaemon) start() error {
+	cmd := exec.Command("/usr/bin/java", javacDaemonHeapArgs, "-XX:+UseSerialGC", "-cp", javaToolsDir, "JavacDaemon")
+	cmd.Dir = os.TempDir()
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = stdin.Close()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		_ = stdin.Close()
+		return fmt.Errorf("failed to start javac daemon: %w", err)
+	}
+	d.cmd = cmd
+	d.stdin = stdin
+	d.stdout = bufio.NewReader(stdout)
+	d.uses = 0
+	return nil
+}
+
+func javacRoundTrip(stdin io.Writer, stdout *bufio.Reader, args []string) (string, int, error) {
+	if _, err := io.WriteString(stdin, strings.Join(args, "\t")+"\n"); err != nil {
+		return "", 0, err
+	}
+	header, err := stdout.ReadString('\n')
+	if err != nil {
+		return "", 0, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("invalid javac daemon response %q", header)
+	}
+	code, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid javac daemon exit code: %w", err)
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("invalid javac daemon output size %q", fields[1])
+	}
+	output := make([]byte, size)
+	if _, err := io.ReadFull(stdout, output); err != nil {
+		return "", 0, err
+	}
+	return string(output), code, nil
+}
+
+// stop 은 mu 를 잡은 상태에서 호출한다.
+func (d *javacDaemon) stop() {
+	if d.cmd == nil {
+		return
+	}
+	_ = d.stdin.Close()
+	_ = d.cmd.Process.Kill()
+	_ = d.cmd.Wait()
+	d.cmd = nil
+	d.stdin = nil
+	d.stdout = nil
+}
+
+// javaRuntimeArgs 는 사용자 프로그램을 실행하는 java 명령에 붙일 옵션이다.
+// 아카이브가 JVM 과 맞지 않으면 -Xshare:auto 가 조용히 CDS 없이 시작한다.
+func javaRuntimeArgs() []string {
+	if !javaCDSEnabled {
+		return nil
+	}
+	return []string{"-Xshare:auto", "-XX:SharedArchiveFile=" + javaCDSArchive}
+}
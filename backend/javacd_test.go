@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestJavacRoundTrip(t *testing.T) {
+	var request bytes.Buffer
+	response := bufio.NewReader(strings.NewReader("1 38\n/code/Main.java:3: error: ';' expected\n"))
+	output, code, err := javacRoundTrip(&request, response, []string{"-d", "/code", "/code/Main.java"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.String() != "-d\t/code\t/code/Main.java\n" {
+		t.Errorf("request = %q", request.String())
+	}
+	if code != 1 || output != "/code/Main.java:3: error: ';' expected" {
+		t.Errorf("output = %q, code = %d", output, code)
+	}
+
+	for _, invalid := range []string{"", "0\n", "x 0\n", "0 -1\n", "0 10\nshort"} {
+		if _, _, err := javacRoundTrip(&bytes.Buffer{}, bufio.NewReader(strings.NewReader(invalid)), nil); err == nil {
+			t.Errorf("response %q was accepted", invalid)
+		}
+	}
+}
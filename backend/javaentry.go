@@ -0,0 +1,187 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	javaPackagePattern = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	javaTypePattern    = regexp.MustCompile(`((?:(?:public|protected|private|final|abstract|sealed|non-sealed|strictfp|static)\s+)*)(?:class|interface|enum|record)\s+(\w+)`)
+	javaMainPattern    = regexp.MustCompile(`\bstatic\b[^;{}()]*\bvoid\s+main\s*\(`)
+	javaPublicPattern  = regexp.MustCompile(`\bpublic\b`)
+)
+
+// JavaEntry 는 소스에서 찾은 패키지, 파일 이름이 되어야 하는 public 클래스, main 이 있는 클래스다.
+type JavaEntry struct {
+	Package   string
+	FileClass string
+	MainClass string
+}
+
+// parseJavaEntry 는 주석과 문자열을 지운 뒤 최상위 타입과 그 안의 static main 을 찾는다.
+// public 클래스에 main 이 있으면 그것을, 없으면 처음으로 main 을 가진 최상위 타입을 쓴다.
+func parseJavaEntry(source string) JavaEntry {
+	code := stripJavaLiterals(source)
+	entry := JavaEntry{}
+	if m := javaPackagePattern.FindStringSubmatch(code); m != nil {
+		entry.Package = m[1]
+	}
+
+	depths := make([]int, len(code)+1)
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		depths[i] = depth
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	depths[len(code)] = depth
+
+	type javaType struct {
+		name    string
+		public  bool
+		start   int
+		hasMain bool
+	}
+	var types []*javaType
+	for _, m := range javaTypePattern.FindAllStringSubmatchIndex(code, -1) {
+		if depths[m[0]] != 0 || (m[0] > 0 && isJavaIdentChar(code[m[0]-1])) {
+			continue
+		}
+		modifiers := code[m[2]:m[3]]
+		types = append(types, &javaType{
+			name:   code[m[4]:m[5]],
+			public: javaPublicPattern.MatchString(modifiers),
+			start:  m[0],
+		})
+	}
+	for _, m := range javaMainPattern.FindAllStringIndex(code, -1) {
+		if depths[m[0]] != 1 {
+			continue
+		}
+		for i := len(types) - 1; i >= 0; i-- {
+			if types[i].start < m[0] {
+				types[i].hasMain = true
+				break
+			}
+		}
+	}
+
+	for _, t := range types {
+		if t.public {
+			entry.FileClass = t.name
+			if t.hasMain {
+				entry.MainClass = t.name
+			}
+			break
+		}
+	}
+	if entry.MainClass == "" {
+		for _, t := range types {
+			if t.hasMain {
+				entry.MainClass = t.name
+				break
+			}
+		}
+	}
+	if entry.FileClass == "" {
+		entry.FileClass = entry.MainClass
+	}
+	if entry.FileClass == "" {
+		entry.FileClass = "Main"
+	}
+	return entry
+}
+
+func isJavaIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// SourcePath 는 javac 가 요구하는 패키지 디렉터리 아래의 파일 경로다.
+func (e JavaEntry) SourcePath() string {
+	dir := workspaceDir
+	if e.Package != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(e.Package, ".", "/")))
+	}
+	return filepath.Join(dir, e.FileClass+".java")
+}
+
+func (e JavaEntry) QualifiedMain() string {
+	if e.Package == "" {
+		return e.MainClass
+	}
+	return e.Package + "." + e.MainClass
+}
+
+// WithJavaEntry 는 찾은 경로와 클래스 이름으로 Java 의 파일/컴파일/실행 명령을 바꾼 복사본을 돌려준다.
+// main 이 없으면 ExecuteCmd 가 비어서 run 모드를 지원하지 않게 된다.
+func (o CompileOption) WithJavaEntry(entry JavaEntry) CompileOption {
+	o.Filename = entry.SourcePath()
+	o.CompileCmd = []string{"/usr/bin/javac", "-d", workspaceDir, o.Filename}
+	o.ExecuteCmd = nil
+	if entry.MainClass != "" {
+		o.ExecuteCmd = append([]string{"/usr/bin/java"}, jvmRunArgs...)
+		o.ExecuteCmd = append(o.ExecuteCmd, javaRuntimeArgs()...)
+		o.ExecuteCmd = append(o.ExecuteCmd, "-cp", workspaceDir, entry.QualifiedMain())
+	}
+	return o
+}
+
+// stripJavaLiterals 는 주석, 문자열, 문자 리터럴을 공백으로 바꾼다. 길이와 줄 위치는 유지한다.
+func stripJavaLiterals(source string) string {
+	out := []byte(source)
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(out); i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(source); {
+		switch {
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				end = len(source) - i
+			}
+			blank(i, i+end)
+			i += end
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				blank(i, len(source))
+				return string(out)
+			}
+			blank(i, i+2+end+2)
+			i += 2 + end + 2
+		case strings.HasPrefix(source[i:], `"""`):
+			end := strings.Index(source[i+3:], `"""`)
+			if end < 0 {
+				blank(i, len(source))
+				return string(out)
+			}
+			blank(i, i+3+end+3)
+			i += 3 + end + 3
+		case source[i] == '"' || source[i] == '\'':
+			quote := source[i]
+			j := i + 1
+			for j < len(source) && source[j] != quote && source[j] != '\n' {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			blank(i, j+1)
+			i = j + 1
+		default:
+			i++
+		}
+	}
+	return string(out)
+}
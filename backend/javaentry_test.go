@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJavaEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   JavaEntry
+	}{
+		{
+			name:   "public main class",
+			source: "public class Main {\n    public static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "Main", MainClass: "Main"},
+		},
+		{
+			name:   "package and other name",
+			source: "package com.example.app;\n\npublic class Hello {\n    public static void main(String... args) {}\n}\n",
+			want:   JavaEntry{Package: "com.example.app", FileClass: "Hello", MainClass: "Hello"},
+		},
+		{
+			name:   "main in non-public class",
+			source: "class Helper {}\n\nclass Runner {\n    static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "Runner", MainClass: "Runner"},
+		},
+		{
+			name:   "public class without main",
+			source: "public class Api {}\n\nclass Runner {\n    public static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "Api", MainClass: "Runner"},
+		},
+		{
+			name:   "nested main is ignored",
+			source: "public class Outer {\n    static class Inner {\n        public static void main(String[] args) {}\n    }\n}\n",
+			want:   JavaEntry{FileClass: "Outer"},
+		},
+		{
+			name:   "comments and strings",
+			source: "// public class Fake { static void main(String[] a) {} }\n/* class Nope {} */\npublic class Real {\n    String s = \"class Str { static void main( }\";\n    String t = \"\"\"\n        public class Block {}\n        \"\"\";\n    public static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "Real", MainClass: "Real"},
+		},
+		{
+			name:   "identifier containing class",
+			source: "public class Main {\n    int subclass = 1;\n    public static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "Main", MainClass: "Main"},
+		},
+		{
+			name:   "record and enum",
+			source: "record Point(int x, int y) {}\n\nenum App {\n    INSTANCE;\n    public static void main(String[] args) {}\n}\n",
+			want:   JavaEntry{FileClass: "App", MainClass: "App"},
+		},
+		{
+			name:   "nothing",
+			source: "",
+			want:   JavaEntry{FileClass: "Main"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseJavaEntry(tt.source); got != tt.want {
+				t.Errorf("parseJavaEntry() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJavaEntryPaths(t *testing.T) {
+	entry := JavaEntry{Package: "com.example", FileClass: "Hello", MainClass: "Hello"}
+	if got, want := entry.SourcePath(), workspaceDir+"/com/example/Hello.java"; got != want {
+		t.Errorf("SourcePath() = %q, want %q", got, want)
+	}
+	if got, want := entry.QualifiedMain(), "com.example.Hello"; got != want {
+		t.Errorf("QualifiedMain() = %q, want %q", got, want)
+	}
+
+	option := CompileOptions[JAVA].WithJavaEntry(entry)
+	want := []string{"/usr/bin/java", "-Xmx256m", "-Xss64m", "-XX:+UseSerialGC", "-cp", workspaceDir, "com.example.Hello"}
+	if !reflect.DeepEqual(option.ExecuteCmd, want) {
+		t.Errorf("ExecuteCmd = %v, want %v", option.ExecuteCmd, want)
+	}
+	if len(option.IsolateArgs) == 0 {
+		t.Error("IsolateArgs were dropped")
+	}
+
+	option = CompileOptions[JAVA].WithJavaEntry(JavaEntry{FileClass: "Lib"})
+	if option.ExecuteCmd != nil {
+		t.Errorf("ExecuteCmd = %v, want nil without a main class", option.ExecuteCmd)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	judgeMaxTestCases     = 50
+	judgeDefaultTimeLimit = 2.0
+	judgeMaxTimeLimit     = 10.0
+	judgeOutputLimit      = 1024 * 1024
+)
+
+const (
+	VerdictAccepted      = "accepted"
+	VerdictWrongAnswer   = "wrong_answer"
+	VerdictTimeLimit     = "time_limit_exceeded"
+	VerdictRuntimeError  = "runtime_error"
+	VerdictOutputLimit   = "output_limit_exceeded"
+	VerdictMissingOutput = "missing_output"
+	VerdictSystemError   = "system_error"
+)
+
+// 출력 비교 방식. ordered/unordered 는 SQL 의 마지막 결과 집합을 ExpectedResult 와 비교한다.
+const (
+	CompareText      = "text"
+	CompareOrdered   = "ordered"
+	CompareUnordered = "unordered"
+)
+
+// JudgeSpec 은 judge 모드의 테스트 케이스와 입출력 방식이다.
+// InputFile/OutputFile 이 비어 있으면 stdin/stdout 을 쓰고, 있으면 workspace 의 해당 파일을 쓴다.
+type JudgeSpec struct {
+	TestCases  []JudgeTestCase `json:"test_cases"`
+	TimeLimit  float64         `json:"time_limit"`
+	InputFile  string          `json:"input_file"`
+	OutputFile string          `json:"output_file"`
+	Compare    string          `json:"compare"`
+}
+
+type JudgeTestCase struct {
+	Input          string        `json:"input"`
+	Expected       string        `json:"expected"`
+	ExpectedResult *SQLResultSet `json:"expected_result"`
+}
+
+type JudgeCaseResult struct {
+	Index    int     `json:"index"`
+	Verdict  string  `json:"verdict"`
+	Time     float64 `json:"time"`
+	ExitCode int     `json:"exit_code"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// 테스트 케이스의 기대 출력은 다시 보내지 않는다.
+func runJudge(ctx *ConnectionContext, option CompileOption, params RunParams, spec *JudgeSpec) error {
+	timeLimit := spec.TimeLimit
+	if timeLimit <= 0 {
+		timeLimit = judgeDefaultTimeLimit
+	}
+
+	summary := map[string]int{"total": len(spec.TestCases)}
+	for i, tc := range spec.TestCases {
+		result := judgeTestCase(ctx.box, option, params, spec, timeLimit, i, tc)
+		summary[result.Verdict]++
+		ctx.write(map[string]interface{}{
+			"type":   "judge_result",
+			"result": result,
+		})
+	}
+
+	ctx.write(map[string]interface{}{
+		"type":    "judge_summary",
+		"summary": summary,
+	})
+	ctx.write(map[string]interface{}{
+		"type":        "exit",
+		"return_code": 0,
+		"error":       "",
+	})
+	ctx.finishRun()
+	return nil
+}
+
+func validateJudgeSpec(spec *JudgeSpec, option CompileOption) error {
+	if spec == nil || len(spec.TestCases) == 0 {
+		return errors.New("no test cases")
+	}
+	if len(spec.TestCases) > judgeMaxTestCases {
+		return fmt.Errorf("too many test cases: %d > %d", len(spec.TestCases), judgeMaxTestCases)
+	}
+	if spec.TimeLimit < 0 || spec.TimeLimit > judgeMaxTimeLimit {
+		return fmt.Errorf("time limit must be between 0 and %.0f seconds", judgeMaxTimeLimit)
+	}
+	for _, name := range []string{spec.InputFile, spec.OutputFile} {
+		if name == "" {
+			continue
+		}
+		if _, err := workspacePath(name); err != nil {
+			return err
+		}
+	}
+	if spec.InputFile != "" && spec.InputFile == spec.OutputFile {
+		return errors.New("input_file and output_file must differ")
+	}
+
+	switch spec.Compare {
+	case "", CompareText:
+		if option.SQL {
+			return errors.New("SQL results must be compared with ordered or unordered")
+		}
+	case CompareOrdered, CompareUnordered:
+		if !option.SQL {
+			return fmt.Errorf("%s comparison is only supported for SQL", spec.Compare)
+		}
+		for i, tc := range spec.TestCases {
+			if tc.ExpectedResult == nil {
+				return fmt.Errorf("test case %d has no expected_result", i)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown compare mode: %s", spec.Compare)
+	}
+	return nil
+}
+
+func judgeTestCase(box *Box, option CompileOption, params RunParams, spec *JudgeSpec, timeLimit float64, index int, tc JudgeTestCase) JudgeCaseResult {
+	result := JudgeCaseResult{Index: index}
+	systemError := func(err error) JudgeCaseResult {
+		result.Verdict = VerdictSystemError
+		result.Message = err.Error()
+		return result
+	}
+
+	var outputPath string
+	if spec.OutputFile != "" {
+		// 이전 테스트 케이스의 프로그램이 workspace 경로를 symlink 로 바꿔 놓았을 수 있으므로
+		// 테스트 케이스 사이의 파일 작업은 모두 openat2 기반 함수로 한다.
+		outputPath, _ = box.workspacePath(spec.OutputFile)
+		if err := box.removeAll(outputPath); err != nil {
+			return systemError(err)
+		}
+	}
+
+	// SQL 은 테스트 케이스마다 새 데이터베이스에서 시작한다.
+	if option.SQL {
+		for _, path := range []string{sqlDatabaseFile, sqlResultsFile} {
+			if err := box.removeAll(box.path(path)); err != nil {
+				return systemError(err)
+			}
+		}
+	}
+
+	inputFile := spec.InputFile
+	if inputFile == "" {
+		inputFile = option.JudgeInputFile
+	}
+	var stdin *strings.Reader
+	if inputFile != "" {
+		inputPath, _ := box.workspacePath(inputFile)
+		if err := box.writeFile(inputPath, []byte(tc.Input), 0o644); err != nil {
+			return systemError(err)
+		}
+		stdin = strings.NewReader("")
+	} else {
+		stdin = strings.NewReader(tc.Input)
+	}
+
+	meta, err := os.CreateTemp("", "judge-meta-")
+	if err != nil {
+		return systemError(err)
+	}
+	metaPath := meta.Name()
+	meta.Close()
+	defer os.Remove(metaPath)
+
+	// --fsize 로 출력 파일이 한도를 크게 넘기기 전에 막는다.
+	limit := strconv.FormatFloat(timeLimit, 'f', 3, 64)
+	wallLimit := strconv.FormatFloat(timeLimit*3, 'f', 3, 64)
+	args := box.isolateArgs()
+	args = append(args, option.IsolateArgs...)
+	args = append(args, params.isolateArgs()...)
+	args = append(args,
+		"--time="+limit,
+		"--wall-time="+wallLimit,
+		"--fsize="+strconv.Itoa(judgeOutputLimit/1024+1),
+		"--meta="+metaPath,
+		"--silent",
+		"--run", "--",
+	)
+	args = append(args, option.ExecuteCmd...)
+	args = append(args, params.Args...)
+
+	stdout := &limitedBuffer{limit: judgeOutputLimit}
+	cmd := exec.Command(isolateBinary, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &limitedBuffer{limit: 4 * 1024}
+	_ = cmd.Run()
+
+	status := readIsolateMeta(metaPath)
+	result.Time, _ = strconv.ParseFloat(status["time"], 64)
+	result.ExitCode, _ = strconv.Atoi(status["exitcode"])
+
+	switch status["status"] {
+	case "TO":
+		result.Verdict = VerdictTimeLimit
+		return result
+	case "SG":
+		// SIGXFSZ: --fsize 를 넘겨서 쓰려고 한 경우
+		if status["exitsig"] == "25" {
+			result.Verdict = VerdictOutputLimit
+			return result
+		}
+		result.Verdict = VerdictRuntimeError
+		result.Message = status["message"]
+		return result
+	case "RE":
+		result.Verdict = VerdictRuntimeError
+		result.Message = status["message"]
+		return result
+	case "XX":
+		return systemError(fmt.Errorf("isolate: %s", status["message"]))
+	}
+
+	if spec.Compare == CompareOrdered || spec.Compare == CompareUnordered {
+		statements, err := readSQLResults(box)
+		if err != nil {
+			return systemError(err)
+		}
+		actual := lastResultSet(statements)
+		switch {
+		case actual == nil:
+			result.Verdict = VerdictWrongAnswer
+			result.Message = "no result set"
+		case actual.Truncated:
+			result.Verdict = VerdictOutputLimit
+		case resultSetsMatch(actual, tc.ExpectedResult, spec.Compare == CompareOrdered):
+			result.Verdict = VerdictAccepted
+		default:
+			result.Verdict = VerdictWrongAnswer
+		}
+		return result
+	}
+
+	var output []byte
+	if outputPath != "" {
+		var err error
+		output, err = box.readFile(outputPath, judgeOutputLimit)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Verdict = VerdictMissingOutput
+			result.Message = fmt.Sprintf("%s was not created", spec.OutputFile)
+			return result
+		case errors.Is(err, errNotRegularFile):
+			result.Verdict = VerdictMissingOutput
+			result.Message = fmt.Sprintf("%s is not a regular file", spec.OutputFile)
+			return result
+		case errors.Is(err, errFileTooLarge):
+			result.Verdict = VerdictOutputLimit
+			return result
+		case err != nil:
+			return systemError(err)
+		}
+	} else {
+		if stdout.truncated {
+			result.Verdict = VerdictOutputLimit
+			return result
+		}
+		output = stdout.Bytes()
+	}
+
+	if outputsMatch(string(output), tc.Expected) {
+		result.Verdict = VerdictAccepted
+	} else {
+		result.Verdict = VerdictWrongAnswer
+	}
+	return result
+}
+
+// isolate meta 파일은 "key:value" 줄로 되어 있다.
+func readIsolateMeta(path string) map[string]string {
+	meta := map[string]string{}
+	f, err := os.Open(path)
+	if err != nil {
+		return meta
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), ":"); ok {
+			meta[key] = value
+		}
+	}
+	return meta
+}
+
+// 줄 끝 공백과 마지막 빈 줄은 무시하고 비교한다.
+func outputsMatch(actual string, expected string) bool {
+	return normalizeOutput(actual) == normalizeOutput(expected)
+}
+
+func normalizeOutput(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputsMatch(t *testing.T) {
+	tests := []struct {
+		actual, expected string
+		want             bool
+	}{
+		{"1 2\n3\n", "1 2\n3", true},
+		{"1 2  \r\n3\t\n\n\n", "1 2\n3\n", true},
+		{"1 2\n3\n", "1  2\n3\n", false},
+		{"\n1\n", "1\n", false},
+		{"", "\n", true},
+	}
+	for _, tt := range tests {
+		if got := outputsMatch(tt.actual, tt.expected); got != tt.want {
+			t.Errorf("outputsMatch(%q, %q) = %v, want %v", tt.actual, tt.expected, got, tt.want)
+		}
+	}
+}
+
+func TestValidateJudgeSpec(t *testing.T) {
+	valid := &JudgeSpec{TestCases: []JudgeTestCase{{Input: "1", Expected: "1"}}, InputFile: "in.txt", OutputFile: "out.txt"}
+	if err := validateJudgeSpec(valid, CompileOptions[PYTHON]); err != nil {
+		t.Fatalf("valid spec: %v", err)
+	}
+	sql := &JudgeSpec{TestCases: []JudgeTestCase{{ExpectedResult: &SQLResultSet{}}}, Compare: CompareUnordered}
+	if err := validateJudgeSpec(sql, CompileOptions[SQL]); err != nil {
+		t.Fatalf("valid sql spec: %v", err)
+	}
+
+	tooMany := &JudgeSpec{TestCases: make([]JudgeTestCase, judgeMaxTestCases+1)}
+	tests := map[string]*JudgeSpec{
+		"nil":             nil,
+		"no test cases":   {},
+		"too many":        tooMany,
+		"negative time":   {TestCases: valid.TestCases, TimeLimit: -1},
+		"long time":       {TestCases: valid.TestCases, TimeLimit: judgeMaxTimeLimit + 1},
+		"input traversal": {TestCases: valid.TestCases, InputFile: "../in.txt"},
+		"absolute output": {TestCases: valid.TestCases, OutputFile: "/etc/passwd"},
+		"same files":      {TestCases: valid.TestCases, InputFile: "io.txt", OutputFile: "io.txt"},
+	}
+	for name, spec := range tests {
+		if err := validateJudgeSpec(spec, CompileOptions[PYTHON]); err == nil {
+			t.Errorf("%s: invalid spec was accepted", name)
+		}
+	}
+
+	sqlTests := map[string]struct {
+		spec   *JudgeSpec
+		option CompileOption
+	}{
+		"text compare for sql":    {&JudgeSpec{TestCases: sql.TestCases}, CompileOptions[SQL]},
+		"result compare for text": {&JudgeSpec{TestCases: sql.TestCases, Compare: CompareOrdered}, CompileOptions[PYTHON]},
+		"missing expected result": {&JudgeSpec{TestCases: valid.TestCases, Compare: CompareOrdered}, CompileOptions[SQL]},
+		"unknown compare":         {&JudgeSpec{TestCases: sql.TestCases, Compare: "fuzzy"}, CompileOptions[SQL]},
+	}
+	for name, tt := range sqlTests {
+		if err := validateJudgeSpec(tt.spec, tt.option); err == nil {
+			t.Errorf("%s: invalid spec was accepted", name)
+		}
+	}
+}
+
+func TestReadIsolateMeta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta")
+	data := "time:0.125\ntime-wall:0.300\nstatus:SG\nexitsig:25\nmessage:Caught fatal signal 25\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta := readIsolateMeta(path)
+	if meta["time"] != "0.125" || meta["status"] != "SG" || meta["exitsig"] != "25" || meta["message"] != "Caught fatal signal 25" {
+		t.Errorf("meta = %v", meta)
+	}
+	if meta := readIsolateMeta(filepath.Join(t.TempDir(), "missing")); len(meta) != 0 {
+		t.Errorf("missing meta = %v", meta)
+	}
+}
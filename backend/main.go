@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,14 +13,22 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
-// 하나의 Pod - 하나의 isolate(boxID 0)
+
+// 연결마다 풀에서 빌린 isolate box 하나를 쓴다. (box.go)
 const (
 	isolateBinary = "/usr/local/bin/isolate"
-	workspaceDir  = "/code"
-	boxID         = "0"
+	// isolate --init 이 box 마다 만드는 디렉터리. 샌드박스 안에서는 <id>/box 가 /box 로 보인다.
+	isolateBoxRoot = "/var/local/lib/isolate"
+	workspaceDir   = "/code"
+	// isolate 는 box 마다 first_uid+box id 사용자로 프로그램을 실행한다. isolate 기본 설정 값이다.
+	isolateFirstUID = 60000
+	isolateFirstGID = 60000
+	// code 메시지에서 archive 를 뺀 나머지(소스, files, test_files 등)에 허용하는 크기
+	messageMaxOverhead = 2 * 1024 * 1024
 )
 
 type Message struct {
@@ -25,14 +36,66 @@ type Message struct {
 	Language string `json:"language"`
 	Source   string `json:"source"`
 	Data     string `json:"data"`
+	Mode     string `json:"mode"`
+	Stdin    string `json:"stdin"`
+	Coverage bool   `json:"coverage"`
+	// base64 로 인코딩한 zip 또는 tar.gz. 바이너리 프레임으로 먼저 보내도 된다.
+	Archive string `json:"archive"`
+	// get_file 에서 읽을 workspace 상대 경로
+	Path string `json:"path"`
+
+	// 실행 인자, 환경 변수, stdin 으로 연결할 workspace 파일
+	Args      []string          `json:"args"`
+	Env       map[string]string `json:"env"`
+	StdinFile string            `json:"stdin_file"`
+	// 프로그램이 box 안에서 listen 하는 port. 있으면 /preview/ 로 전달한다.
+	Port int `json:"port"`
+
+	// 메인 소스 외에 workspace 에 함께 쓸 파일들
+	Files []WorkspaceFile `json:"files"`
+	// test 모드에서 쓰는 출제자 테스트 파일. 내용은 클라이언트에 다시 보내지 않는다.
+	TestFiles []WorkspaceFile `json:"test_files"`
+	// judge 모드의 테스트 케이스와 입출력 파일 설정
+	Judge *JudgeSpec `json:"judge"`
+	// SQL 실행 전에 적용할 출제자 스키마와 시드
+	Database *SQLDatabase `json:"database"`
+
+	// repl 모드의 eval. 코드는 Source 로 받고 Cell 은 결과 이벤트에 그대로 돌려준다.
+	Cell    string  `json:"cell"`
+	Timeout float64 `json:"timeout"`
+
+	// debug 모드
+	Breakpoints []int  `json:"breakpoints"`
+	Command     string `json:"command"`
+	Line        int    `json:"line"`
+	Breakpoint  int    `json:"breakpoint"`
+	Frame       int    `json:"frame"`
+	Expression  string `json:"expression"`
+}
+
+type WorkspaceFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
 }
 
 type ConnectionContext struct {
 	conn *websocket.Conn
+	box  *Box
+	// 웹소켓 없이 파이프라인을 실행할 때(시작 self-test) 이벤트를 받는다.
+	sink func(v interface{})
 
 	stateMu   sync.Mutex
 	cmd       *exec.Cmd
 	stdinPipe io.WriteCloser
+	debug     *debugSession
+	repl      *replSession
+	archive   []byte
+	hidden    map[string]bool
+	// 실행이 끝나서 idle timeout 이 걸려 있는지
+	exited bool
+
+	// 종료 후 workspace 산출물(coverage 등)을 수집 중인 작업. 수집이 끝나기 전에 workspace 를 지우지 않는다.
+	artifacts sync.WaitGroup
 
 	writeMu sync.Mutex
 }
@@ -40,9 +103,43 @@ type ConnectionContext struct {
 func (ctx *ConnectionContext) write(v interface{}) {
 	ctx.writeMu.Lock()
 	defer ctx.writeMu.Unlock()
+	if ctx.sink != nil {
+		ctx.sink(v)
+		return
+	}
 	sendJSON(ctx.conn, v)
 }
 
+// finishRun 은 실행이 끝났을 때 호출한다. 클라이언트가 결과 파일을 받아 갈 수 있도록 연결은 바로 닫지 않고,
+// exitIdleTimeout 동안 메시지가 없을 때 닫는다. 자체 검사(selftest.go)처럼 연결이 없으면 표시만 한다.
+func (ctx *ConnectionContext) finishRun() {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.exited = true
+	if ctx.conn != nil {
+		_ = ctx.conn.SetReadDeadline(time.Now().Add(exitIdleTimeout))
+	}
+}
+
+// touch 는 실행이 끝난 뒤에 온 메시지마다 idle timeout 을 다시 건다.
+func (ctx *ConnectionContext) touch() {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	if ctx.exited && ctx.conn != nil {
+		_ = ctx.conn.SetReadDeadline(time.Now().Add(exitIdleTimeout))
+	}
+}
+
+// startRun 은 새 실행을 시작할 때 idle timeout 을 푼다.
+func (ctx *ConnectionContext) startRun() {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.exited = false
+	if ctx.conn != nil {
+		_ = ctx.conn.SetReadDeadline(time.Time{})
+	}
+}
+
 // ctx 에 stdinPipe 연결해서, 입력 이벤트에서 사용
 func (ctx *ConnectionContext) setProcess(cmd *exec.Cmd, stdin io.WriteCloser) {
 	ctx.stateMu.Lock()
@@ -64,14 +161,79 @@ func (ctx *ConnectionContext) stdin() io.WriteCloser {
 	return ctx.stdinPipe
 }
 
+func (ctx *ConnectionContext) setDebugSession(session *debugSession) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.debug = session
+}
+
+func (ctx *ConnectionContext) debugSession() *debugSession {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	return ctx.debug
+}
+
+func (ctx *ConnectionContext) setReplSession(session *replSession) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.repl = session
+}
+
+func (ctx *ConnectionContext) replSession() *replSession {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	return ctx.repl
+}
+
+func (ctx *ConnectionContext) setPendingArchive(data []byte) {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.archive = data
+}
+
+func (ctx *ConnectionContext) takePendingArchive() []byte {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	data := ctx.archive
+	ctx.archive = nil
+	return data
+}
+
+// 출제자 테스트 파일처럼 파일 목록/다운로드에서 숨길 workspace 상대 경로
+func (ctx *ConnectionContext) setHiddenFiles(files []WorkspaceFile) {
+	hidden := map[string]bool{}
+	for _, file := range files {
+		hidden[filepath.Clean(file.Name)] = true
+	}
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	ctx.hidden = hidden
+}
+
+func (ctx *ConnectionContext) hiddenFiles() map[string]bool {
+	ctx.stateMu.Lock()
+	defer ctx.stateMu.Unlock()
+	return ctx.hidden
+}
+
 func (ctx *ConnectionContext) stopProcess() {
 	ctx.stateMu.Lock()
 	cmd := ctx.cmd
 	stdin := ctx.stdinPipe
+	debug := ctx.debug
+	repl := ctx.repl
 	ctx.cmd = nil
 	ctx.stdinPipe = nil
+	ctx.debug = nil
+	ctx.repl = nil
 	ctx.stateMu.Unlock()
 
+	if debug != nil {
+		debug.close()
+	}
+	if repl != nil {
+		repl.close()
+	}
 	if stdin != nil {
 		_ = stdin.Close()
 	}
@@ -80,6 +242,9 @@ func (ctx *ConnectionContext) stopProcess() {
 	}
 }
 
+// 실행이 끝난 뒤 클라이언트가 이 시간 동안 아무 메시지도 보내지 않으면 연결을 닫는다.
+var exitIdleTimeout = 2 * time.Minute
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -87,8 +252,18 @@ var upgrader = websocket.Upgrader{
 }
 
 func main() {
-	http.HandleFunc("/ws", wsHandler)
+	configureGoCache()
+	warmJavacDaemon()
+	startReadinessChecks()
+
+	http.HandleFunc("/ws", fromManager(wsHandler))
 	http.HandleFunc("/healthz", healthHandler)
+	http.HandleFunc("/readyz", readyHandler)
+	http.HandleFunc("/format", fromManager(formatHandler))
+	http.HandleFunc(previewPathPrefix, fromManager(previewHandler))
+	http.HandleFunc("/capabilities", fromManager(capabilitiesHandler))
+	http.HandleFunc("/status", fromManager(statusHandler))
+	http.HandleFunc("/reset", fromManager(resetHandler))
 
 	addr := ":8000"
 	log.Printf("WebSocket server running on %s\n", addr)
@@ -103,32 +278,62 @@ func healthHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 func wsHandler(w http.ResponseWriter, r *http.Request) {
+	// 업그레이드 전에 box 를 빌린다. 빈 box 가 없으면 다른 세션이 쓰고 있는 것이므로
+	// workspace 를 나눠 쓰지 않도록 409 로 거절한다. pod manager 는 box 수보다 많이 보내지 않는다.
+	box, err := boxes.acquire()
+	if errors.Is(err, errNoFreeBox) {
+		http.Error(w, "runner is busy", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("isolate init error:", err)
+		http.Error(w, fmt.Sprintf("failed to init isolate: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
+		if releaseErr := boxes.release(box); releaseErr != nil {
+			log.Println("isolate cleanup error:", releaseErr)
+		}
 		return
 	}
 
-	ctx := &ConnectionContext{conn: conn}
+	ctx := &ConnectionContext{conn: conn, box: box}
 	defer func() {
 		ctx.stopProcess()
-		if cleanupErr := cleanupIsolate(); cleanupErr != nil {
-			log.Println("isolate cleanup error:", cleanupErr)
+		ctx.artifacts.Wait()
+		if releaseErr := boxes.release(box); releaseErr != nil {
+			log.Println("isolate cleanup error:", releaseErr)
 		}
 		_ = conn.Close()
 	}()
 
-	if err := initIsolate(); err != nil {
-		ctx.write(map[string]interface{}{
-			"type":  "error",
-			"error": fmt.Sprintf("failed to init isolate: %v", err),
-		})
-		return
-	}
+	serveConnection(ctx)
+}
 
+// serveConnection 은 연결이 끊기거나 exit 메시지가 올 때까지 클라이언트 메시지를 처리한다.
+func serveConnection(ctx *ConnectionContext) {
+	conn := ctx.conn
+	// 바이너리 프레임은 archive 로, 다음 code 메시지에서 workspace 에 푼다.
+	// JSON 의 archive 필드는 base64 라서 4/3 배 커지고, 소스와 파일 목록이 함께 올 수 있다.
+	// 한도를 조금 넘는 archive 는 연결을 끊지 않고 archive_error 로 이유를 알려 준다.
+	conn.SetReadLimit(int64(base64.StdEncoding.EncodedLen(archiveMaxCompressed)) + messageMaxOverhead)
 	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			log.Println("ReadMessage error:", err)
+			break
+		}
+		ctx.touch()
+		if messageType == websocket.BinaryMessage {
+			ctx.setPendingArchive(data)
+			continue
+		}
+
 		var msg Message
-		if err := conn.ReadJSON(&msg); err != nil {
+		if err := json.Unmarshal(data, &msg); err != nil {
 			log.Println("ReadJSON error:", err)
 			break
 		}
@@ -140,6 +345,44 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+		case "format":
+			handleFormat(ctx, &msg)
+
+		case "capabilities":
+			handleCapabilities(ctx)
+
+		case "list_files":
+			handleListFiles(ctx)
+
+		case "get_file":
+			handleGetFile(ctx, msg.Path)
+
+		case "debug":
+			session := ctx.debugSession()
+			if session == nil {
+				ctx.write(map[string]interface{}{
+					"type":  "error",
+					"error": "no debug session",
+				})
+				continue
+			}
+			session.handle(&msg)
+
+		case "eval", "reset":
+			session := ctx.replSession()
+			if session == nil {
+				ctx.write(map[string]interface{}{
+					"type":  "error",
+					"error": "no repl session",
+				})
+				continue
+			}
+			if msg.Type == "eval" {
+				session.eval(&msg)
+			} else {
+				session.reset()
+			}
+
 		case "input":
 			stdin := ctx.stdin()
 			if stdin == nil {
@@ -187,9 +430,75 @@ func handleCode(ctx *ConnectionContext, msg *Message) error {
 	if !ok {
 		return fmt.Errorf("unsupported language: %s", msg.Language)
 	}
+	if reason, disabled := languageDisabled(msg.Language); disabled {
+		ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": fmt.Sprintf("%s is temporarily unavailable: %s", msg.Language, reason),
+		})
+		return fmt.Errorf("%s is disabled: %s", msg.Language, reason)
+	}
+
+	mode := msg.Mode
+	if mode == "" {
+		mode = ModeRun
+	}
+	if !option.SupportsMode(mode) {
+		ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": fmt.Sprintf("%s mode is not supported for %s", mode, msg.Language),
+		})
+		return fmt.Errorf("%s mode is not supported for %s", mode, msg.Language)
+	}
+
+	if msg.Coverage {
+		if option.Coverage == nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("coverage is not supported for %s", msg.Language),
+			})
+			return fmt.Errorf("coverage is not supported for %s", msg.Language)
+		}
+		option = option.WithCoverage()
+	}
+
+	if option.DetectJavaEntry && msg.Source != "" {
+		entry := parseJavaEntry(msg.Source)
+		option = option.WithJavaEntry(entry)
+		if entry.MainClass == "" && (mode == ModeRun || mode == ModeJudge) {
+			message := "no entry point found: add `public static void main(String[] args)` to a top-level class"
+			ctx.write(map[string]interface{}{
+				"type":        "compile_error",
+				"stderr":      message,
+				"diagnostics": []Diagnostic{},
+			})
+			return errors.New(message)
+		}
+	}
+
+	params, err := validateRunParams(msg)
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": fmt.Sprintf("invalid run parameters: %v", err),
+		})
+		return err
+	}
+
+	if mode == ModeJudge {
+		if err := validateJudgeSpec(msg.Judge, option); err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("invalid judge spec: %v", err),
+			})
+			return err
+		}
+	}
 
 	ctx.stopProcess()
-	if err := resetWorkspace(); err != nil {
+	ctx.artifacts.Wait()
+	ctx.startRun()
+	ctx.setHiddenFiles(msg.TestFiles)
+	if err := ctx.box.resetWorkspace(); err != nil {
 		ctx.write(map[string]interface{}{
 			"type":  "error",
 			"error": fmt.Sprintf("failed to reset workspace: %v", err),
@@ -197,7 +506,43 @@ func handleCode(ctx *ConnectionContext, msg *Message) error {
 		return err
 	}
 
-	if err := os.WriteFile(option.Filename, []byte(msg.Source), 0o644); err != nil {
+	archive := ctx.takePendingArchive()
+	if msg.Archive != "" {
+		decoded, err := decodeArchive(msg.Archive)
+		if err != nil {
+			writeArchiveError(ctx, err)
+			return err
+		}
+		archive = decoded
+	}
+	if archive != nil {
+		if err := extractArchive(ctx.box, archive); err != nil {
+			writeArchiveError(ctx, err)
+			return err
+		}
+	}
+
+	if option.Filename != "" && (archive == nil || msg.Source != "") {
+		// Java 패키지처럼 소스 경로가 하위 디렉터리일 수 있다.
+		filename := ctx.box.path(option.Filename)
+		err := ctx.box.mkdirAll(filepath.Dir(filename))
+		if err == nil {
+			err = os.WriteFile(filename, []byte(msg.Source), 0o644)
+		}
+		if err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to write file: %v", err),
+			})
+			return err
+		}
+	}
+
+	files := msg.Files
+	if mode == ModeTest {
+		files = append(append([]WorkspaceFile{}, msg.Files...), msg.TestFiles...)
+	}
+	if err := writeWorkspaceFiles(ctx.box, files); err != nil {
 		ctx.write(map[string]interface{}{
 			"type":  "error",
 			"error": fmt.Sprintf("failed to write file: %v", err),
@@ -205,24 +550,115 @@ func handleCode(ctx *ConnectionContext, msg *Message) error {
 		return err
 	}
 
-	if len(option.CompileCmd) > 0 {
-		output, compileErr := runCommand(option.CompileCmd)
+	if option.SQL {
+		if err := writeSQLDatabase(ctx.box, msg.Database); err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to write database scripts: %v", err),
+			})
+			return err
+		}
+	}
+
+	if option.coverage {
+		// 실행 중인 프로그램이 coverage 데이터를 쓸 수 있도록 샌드박스 사용자에게 넘긴다.
+		dir := ctx.box.path(coverageDataDir)
+		err := os.MkdirAll(dir, 0o755)
+		if err == nil {
+			err = ctx.box.chown(dir)
+		}
+		if err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to prepare coverage: %v", err),
+			})
+			return err
+		}
+	}
+
+	if option.GoCache {
+		if err := prepareGoCache(ctx.box); err != nil {
+			// 캐시가 없어도 컴파일은 되므로 느려질 뿐이다.
+			log.Println("go cache error:", err)
+		}
+	}
+
+	if option.Project {
+		return runProject(ctx, option, params)
+	}
+	if mode == ModeTrace {
+		return runTrace(ctx, option, msg.Stdin)
+	}
+	if mode == ModeTest {
+		return runTests(ctx, option, msg.TestFiles)
+	}
+	if mode == ModeRepl {
+		if err := startReplSession(ctx, option, params); err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to start repl: %v", err),
+			})
+			return err
+		}
+		return nil
+	}
+
+	compileCmd := option.CompileCmd
+	if mode == ModeDebug {
+		compileCmd = option.DebugCompileCmd
+	}
+
+	metrics := RunMetrics{CDS: option.JavacDaemon && javaCDSEnabled}
+	if len(compileCmd) > 0 {
+		var output string
+		var cached bool
+		var compileErr error
+		start := time.Now()
+		metrics.Compiler = filepath.Base(compileCmd[0])
+		switch {
+		case option.CompileCache:
+			output, cached, compileErr = compileWithCache(ctx.box, compileCmd)
+		case option.JavacDaemon:
+			output, metrics.Compiler, compileErr = compileJava(ctx.box, compileCmd)
+		default:
+			output, compileErr = ctx.box.runCommand(compileCmd)
+		}
+		metrics.CompileMs = elapsedMs(start)
+		metrics.Cached = cached
 		if compileErr != nil {
 			ctx.write(map[string]interface{}{
-				"type":   "compile_error",
-				"stderr": output,
+				"type":        "compile_error",
+				"stderr":      output,
+				"diagnostics": parseDiagnostics(output),
 			})
 			return compileErr
 		}
 
 		ctx.write(map[string]interface{}{
-			"type":   "compile_success",
-			"stdout": output,
+			"type":        "compile_success",
+			"stdout":      output,
+			"diagnostics": parseDiagnostics(output),
+			"cached":      cached,
 		})
 	}
 
+	if mode == ModeDebug {
+		if err := startDebugSession(ctx, option, msg.Breakpoints); err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to start debugger: %v", err),
+			})
+			return err
+		}
+		return nil
+	}
+
+	if mode == ModeJudge {
+		return runJudge(ctx, option, params, msg.Judge)
+	}
+
 	if len(option.ExecuteCmd) > 0 {
-		if err := runInteractive(ctx, option.ExecuteCmd); err != nil {
+		if err := runInteractive(ctx, option, msg.Source, params, metrics); err != nil {
 			log.Println("runInteractive error:", err)
 			return err
 		}
@@ -231,21 +667,31 @@ func handleCode(ctx *ConnectionContext, msg *Message) error {
 	return nil
 }
 
-func resetWorkspace() error {
-	entries, err := os.ReadDir(workspaceDir)
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range entries {
-		target := filepath.Join(workspaceDir, entry.Name())
-		if err := os.RemoveAll(target); err != nil {
-			return fmt.Errorf("failed to remove %s: %w", target, err)
+// 추가 파일은 workspace 바로 아래 또는 하위 디렉터리에만 쓸 수 있다.
+func writeWorkspaceFiles(box *Box, files []WorkspaceFile) error {
+	for _, file := range files {
+		target, err := box.workspacePath(file.Name)
+		if err != nil {
+			return err
+		}
+		if err := box.mkdirAll(filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(file.Content), 0o644); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+func workspacePath(name string) (string, error) {
+	cleaned := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file name: %q", name)
+	}
+	return filepath.Join(workspaceDir, cleaned), nil
+}
+
 func runCommand(args []string) (string, error) {
 	if len(args) == 0 {
 		return "", fmt.Errorf("no command to run")
@@ -256,95 +702,196 @@ func runCommand(args []string) (string, error) {
 	return string(output), err
 }
 
-func initIsolate() error {
-	args := append(isolateCommonArgs(), "--init")
-	output, err := exec.Command(isolateBinary, args...).CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
-	}
-	return nil
-}
-
-func cleanupIsolate() error {
-	args := append(isolateCommonArgs(), "--cleanup")
-	output, err := exec.Command(isolateBinary, args...).CombinedOutput()
+func runIsolateBoxCommand(box string, action string) error {
+	output, err := exec.Command(isolateBinary, "--box-id="+box, action).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
 	}
 	return nil
 }
 
-func isolateCommonArgs() []string {
-	return []string{
-		"--box-id=" + boxID,
-		"--dir=/code",
-		"--dir=/usr/bin",
-	}
-}
-
-func runInteractive(ctx *ConnectionContext, commandArgs []string) error {
-	if len(commandArgs) == 0 {
+// source 는 클라이언트가 보낸 소스다. sanitizer 리포트 위치의 코드 줄을 여기서 찾는다.
+// 실행이 끝난 뒤의 workspace 파일은 프로그램이 symlink 로 바꿔 놓았을 수 있으므로 다시 읽지 않는다.
+func runInteractive(ctx *ConnectionContext, option CompileOption, source string, params RunParams, metrics RunMetrics) error {
+	if len(option.ExecuteCmd) == 0 {
 		return fmt.Errorf("no command to run")
 	}
 
-	args := isolateCommonArgs()
+	// 사용자 인자는 "--" 뒤 실행 명령 다음에만 붙여서 isolate 옵션으로 해석되지 않게 한다.
+	args := ctx.box.isolateArgs()
+	args = append(args, option.IsolateArgs...)
+	args = append(args, imageIsolateArgs...)
+	args = append(args, params.isolateArgs()...)
+	if params.Port != 0 {
+		// 러너가 만든 namespace 를 isolate 가 그대로 쓰게 한다. 그 namespace 에는 loopback 만 있다.
+		args = append(args, "--share-net")
+	}
 	args = append(args, "--run", "--")
-	args = append(args, commandArgs...)
+	args = append(args, option.ExecuteCmd...)
+	args = append(args, params.Args...)
 
 	cmd := exec.Command(isolateBinary, args...)
 
-	stdinPipe, err := cmd.StdinPipe()
+	before, err := listWorkspace(ctx.box, ctx.hiddenFiles())
 	if err != nil {
 		return err
 	}
 
+	// stdin 파일이 있으면 대화형 입력 대신 파일을 연결한다. 이때 input 메시지는 무시된다.
+	var stdinPipe io.WriteCloser
+	closeStdin := func() {
+		if stdinPipe != nil {
+			_ = stdinPipe.Close()
+		}
+	}
+	if params.StdinFile != "" {
+		stdinFile, err := params.openStdinFile(ctx.box)
+		if err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": err.Error(),
+			})
+			return err
+		}
+		defer stdinFile.Close()
+		cmd.Stdin = stdinFile
+	} else {
+		stdinPipe, err = cmd.StdinPipe()
+		if err != nil {
+			return err
+		}
+	}
+
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
-		_ = stdinPipe.Close()
+		closeStdin()
 		return err
 	}
 
 	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
-		_ = stdinPipe.Close()
+		closeStdin()
 		_ = stdoutPipe.Close()
 		return err
 	}
 
-	if err := cmd.Start(); err != nil {
-		_ = stdinPipe.Close()
+	// 그림 디렉터리는 프로그램이 시작하기 전에 있어야 한다.
+	images, err := startImageWatcher(ctx)
+	if err != nil {
+		log.Println("image watcher error:", err)
+	}
+
+	start := time.Now()
+	var netns *os.File
+	if params.Port != 0 {
+		netns, err = inNewNetns(cmd.Start)
+	} else {
+		err = cmd.Start()
+	}
+	if err != nil {
+		closeStdin()
 		_ = stdoutPipe.Close()
 		_ = stderrPipe.Close()
+		if images != nil {
+			images.close()
+		}
 		return err
 	}
 
+	var stderrCapture *limitedBuffer
+	if option.Sanitizer {
+		stderrCapture = &limitedBuffer{limit: sanitizerStderrLimit}
+	}
+
 	ctx.setProcess(cmd, stdinPipe)
-	go streamOutput(ctx, stdoutPipe, "stdout")
-	go streamOutput(ctx, stderrPipe, "stderr")
+	ctx.artifacts.Add(1)
+
+	unregisterPreview := func() {}
+	if params.Port != 0 {
+		token, unregister, err := registerPreview(netns, params.Port)
+		if err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": err.Error(),
+			})
+		} else {
+			unregisterPreview = unregister
+			ctx.write(map[string]interface{}{
+				"type":  "preview",
+				"port":  params.Port,
+				"token": token,
+				"path":  previewPathPrefix + token + "/",
+			})
+		}
+	}
+
+	// Wait 는 파이프를 닫으므로 출력을 모두 읽은 뒤에 호출해야 한다.
+	var streams sync.WaitGroup
+	streams.Add(2)
+	go func() {
+		defer streams.Done()
+		streamOutput(ctx, stdoutPipe, "stdout", nil)
+	}()
+	go func() {
+		defer streams.Done()
+		if stderrCapture != nil {
+			streamOutput(ctx, stderrPipe, "stderr", stderrCapture)
+		} else {
+			streamOutput(ctx, stderrPipe, "stderr", nil)
+		}
+	}()
 
 	go func() {
+		defer ctx.artifacts.Done()
+		streams.Wait()
 		waitErr := cmd.Wait()
 		exitCode := cmd.ProcessState.ExitCode()
+		metrics.RunMs = elapsedMs(start)
 		ctx.clearProcess()
+		unregisterPreview()
+
+		if images != nil {
+			images.finish()
+		}
+		if stderrCapture != nil {
+			reports := parseSanitizerReports(stderrCapture.String(), option.Filename, source)
+			if len(reports) > 0 {
+				ctx.write(map[string]interface{}{
+					"type":    "sanitizer_report",
+					"reports": reports,
+				})
+			}
+		}
+		if option.SQL {
+			sendSQLResults(ctx)
+		}
+		if option.coverage {
+			sendCoverage(ctx, option.Coverage, nil)
+		}
+		sendFileManifest(ctx, before)
 
 		ctx.write(map[string]interface{}{
 			"type":        "exit",
 			"return_code": exitCode,
 			"error":       fmt.Sprintf("%v", waitErr),
+			"metrics":     metrics,
 		})
-		_ = ctx.conn.Close()
+		ctx.finishRun()
 	}()
 
 	return nil
 }
 
-func streamOutput(ctx *ConnectionContext, r io.ReadCloser, streamType string) {
+func streamOutput(ctx *ConnectionContext, r io.ReadCloser, streamType string, capture io.Writer) {
 	defer r.Close()
 	buf := make([]byte, 1024)
 
 	for {
 		n, err := r.Read(buf)
 		if n > 0 {
+			if capture != nil {
+				_, _ = capture.Write(buf[:n])
+			}
 			line := string(buf[:n])
 			ctx.write(map[string]interface{}{
 				"type": streamType,
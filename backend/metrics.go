@@ -0,0 +1,17 @@
+package main
+
+import "time"
+
+// RunMetrics 는 exit 이벤트에 붙여 보내는 실행 단계별 소요 시간이다.
+type RunMetrics struct {
+	CompileMs int64  `json:"compile_ms"`
+	Compiler  string `json:"compiler,omitempty"`
+	Cached    bool   `json:"cached"`
+	// java 를 CDS 아카이브로 시작했는지
+	CDS   bool  `json:"cds,omitempty"`
+	RunMs int64 `json:"run_ms"`
+}
+
+func elapsedMs(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
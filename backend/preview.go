@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// 미리보기를 여는 프로그램은 러너가 만든, loopback 만 있는 네트워크 namespace 에서 실행된다.
+// 러너는 그 namespace 로 들어가서 연결을 만들고 /preview/<token>/ 요청을 전달한다.
+const (
+	previewPathPrefix  = "/preview/"
+	previewMinPort     = 1024
+	previewDialTimeout = 5 * time.Second
+	previewCSP         = "sandbox allow-scripts allow-forms allow-popups allow-modals"
+)
+
+type previewTarget struct {
+	port  int
+	proxy *httputil.ReverseProxy
+
+	// 실행이 끝나 namespace 를 닫은 뒤에는 dial 하지 않는다.
+	mu     sync.RWMutex
+	netns  *os.File
+	closed bool
+}
+
+var (
+	previewMu      sync.Mutex
+	previewTargets = map[string]*previewTarget{}
+)
+
+// registerPreview 는 netns 안의 port 를 새 토큰으로 등록한다. netns 는 등록이 가져가서 해제할 때 닫는다.
+// 반환된 함수로 등록을 해제하며, 실행이 끝나면 반드시 호출해야 한다.
+func registerPreview(netns *os.File, port int) (string, func(), error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		netns.Close()
+		return "", nil, fmt.Errorf("failed to generate preview token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	target := &previewTarget{netns: netns, port: port}
+	target.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+			r.Out.Host = r.Out.URL.Host
+			r.Out.URL.Path = "/" + strings.TrimPrefix(r.In.URL.Path, previewPathPrefix+token+"/")
+			r.Out.URL.RawPath = ""
+			// pod manager 가 붙인 러너 토큰은 모든 러너에서 통하므로 사용자 프로그램에 넘기지 않는다.
+			r.Out.Header.Del(runnerTokenHeader)
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return target.dial(address)
+			},
+			DisableKeepAlives:     true,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("preview is not reachable: %v", err), http.StatusBadGateway)
+		},
+	}
+
+	previewMu.Lock()
+	previewTargets[token] = target
+	previewMu.Unlock()
+
+	unregister := func() {
+		previewMu.Lock()
+		delete(previewTargets, token)
+		previewMu.Unlock()
+		target.close()
+	}
+	return token, unregister, nil
+}
+
+func lookupPreview(token string) *previewTarget {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+	return previewTargets[token]
+}
+
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, previewPathPrefix)
+	token, _, hasSlash := strings.Cut(rest, "/")
+
+	// 미리보기는 사용자 프로그램이 만든 페이지이므로 러너와 같은 origin 으로 취급되지 않게 한다.
+	w.Header().Set("Content-Security-Policy", previewCSP)
+
+	target := lookupPreview(token)
+	if token == "" || target == nil {
+		http.Error(w, "preview not found", http.StatusNotFound)
+		return
+	}
+	// 상대 경로 자원이 토큰 아래로 요청되도록 디렉터리 경로로 맞춘다.
+	if !hasSlash {
+		http.Redirect(w, r, previewPathPrefix+token+"/", http.StatusFound)
+		return
+	}
+	target.proxy.ServeHTTP(w, r)
+}
+
+func (t *previewTarget) dial(address string) (net.Conn, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return nil, errors.New("sandboxed program is not running")
+	}
+	var conn net.Conn
+	err := inNetns(t.netns, func() error {
+		var dialErr error
+		conn, dialErr = net.DialTimeout("tcp", address, previewDialTimeout)
+		return dialErr
+	})
+	return conn, err
+}
+
+func (t *previewTarget) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		t.netns.Close()
+	}
+}
+
+func setLoopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("get lo flags: %w", err)
+	}
+	flags := ifr.Uint16()
+	if flags&unix.IFF_UP != 0 {
+		return nil
+	}
+	ifr.SetUint16(flags | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("set lo up: %w", err)
+	}
+	return nil
+}
+
+// inNewNetns 는 새 네트워크 namespace 를 만들고 loopback 을 켠 뒤 그 안에서 fn 을 실행한다.
+// 새 namespace 의 loopback 은 꺼져 있어서 127.0.0.1 에 bind 할 수 없으므로, 프로그램을 띄우는 fn 보다 먼저 켠다.
+// fn 이 성공하면 namespace 를 연 파일을 돌려준다.
+func inNewNetns(fn func() error) (*os.File, error) {
+	var netns *os.File
+	err := switchNetns(func() error {
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			return fmt.Errorf("unshare network namespace: %w", err)
+		}
+		return nil
+	}, func() error {
+		if err := setLoopbackUp(); err != nil {
+			return err
+		}
+		f, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			f.Close()
+			return err
+		}
+		netns = f
+		return nil
+	})
+	return netns, err
+}
+
+// inNetns 는 netns 안에서 fn 을 실행한다.
+func inNetns(netns *os.File, fn func() error) error {
+	return switchNetns(func() error {
+		if err := unix.Setns(int(netns.Fd()), unix.CLONE_NEWNET); err != nil {
+			return fmt.Errorf("setns: %w", err)
+		}
+		return nil
+	}, fn)
+}
+
+// switchNetns 는 OS 스레드 하나를 enter 로 다른 네트워크 namespace 에 옮겨서 fn 을 실행하고 되돌린다.
+// fn 에서 만든 소켓과 프로세스는 namespace 에 묶이므로 스레드를 되돌린 뒤에도 그대로 쓸 수 있다.
+func switchNetns(enter func() error, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		original, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		defer original.Close()
+
+		if err := enter(); err != nil {
+			runtime.UnlockOSThread()
+			done <- err
+			return
+		}
+		fnErr := fn()
+
+		// 되돌리지 못한 스레드는 잠근 채로 goroutine 을 끝내서 버린다.
+		if err := unix.Setns(int(original.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		done <- fnErr
+	}()
+	return <-done
+}
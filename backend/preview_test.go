@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPreviewProxiesIntoNetns(t *testing.T) {
+	// loopback 은 프로그램이 listen 하기 전에 켜져 있어야 한다.
+	var listener net.Listener
+	netns, err := inNewNetns(func() error {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		return err
+	})
+	if errors.Is(err, unix.EPERM) || errors.Is(err, os.ErrPermission) {
+		t.Skipf("cannot create a network namespace: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "path="+r.URL.Path+r.Header.Get(runnerTokenHeader))
+	}))
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	token, unregister, err := registerPreview(netns, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 러너 토큰은 프로그램에 넘기지 않는다.
+	request := httptest.NewRequest("GET", previewPathPrefix+token+"/index.html", nil)
+	request.Header.Set(runnerTokenHeader, "secret")
+	recorder := httptest.NewRecorder()
+	previewHandler(recorder, request)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "path=/index.html" {
+		t.Fatalf("response = %d %q", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get("Content-Security-Policy"); got != previewCSP {
+		t.Fatalf("Content-Security-Policy = %q", got)
+	}
+
+	recorder = httptest.NewRecorder()
+	previewHandler(recorder, httptest.NewRequest("GET", previewPathPrefix+token, nil))
+	if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != previewPathPrefix+token+"/" {
+		t.Fatalf("response = %d %v", recorder.Code, recorder.Header())
+	}
+
+	unregister()
+	recorder = httptest.NewRecorder()
+	previewHandler(recorder, httptest.NewRequest("GET", previewPathPrefix+token+"/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("response after unregister = %d", recorder.Code)
+	}
+	if _, err := (&previewTarget{netns: netns, closed: true}).dial(listener.Addr().String()); err == nil {
+		t.Fatal("dialed a closed preview")
+	}
+}
+
+func TestPreviewHandlerUnknownToken(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	previewHandler(recorder, httptest.NewRequest("GET", previewPathPrefix+"missing/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("code = %d", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Security-Policy"); got != previewCSP {
+		t.Fatalf("Content-Security-Policy = %q", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	projectBuildOutputLimit = 256 * 1024
+	projectManifestLimit    = 64 * 1024
+)
+
+// 빌드 스크립트는 사용자가 올린 것이므로 컴파일과 달리 isolate 안에서 실행한다.
+var projectBuildArgs = []string{
+	"--processes",
+	"--time=60",
+	"--wall-time=120",
+	"--fsize=65536",
+	"--env=HOME=/box",
+	"--env=PATH=/usr/local/bin:/usr/bin:/bin",
+	"--env=GOCACHE=/box/.cache/go-build",
+	"--env=GOPROXY=off",
+	"--env=GOFLAGS=-mod=mod",
+}
+
+type projectManifestFile struct {
+	Build string   `json:"build"`
+	Run   []string `json:"run"`
+}
+
+func runProject(ctx *ConnectionContext, option CompileOption, params RunParams) error {
+	manifest, err := readProjectManifest(ctx.box)
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":   "compile_error",
+			"stderr": fmt.Sprintf("invalid %s: %v", projectManifest, err),
+		})
+		return err
+	}
+
+	system, err := detectBuildSystem(ctx.box, manifest.Build)
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":   "compile_error",
+			"stderr": err.Error(),
+		})
+		return err
+	}
+
+	before, err := executableFiles(ctx.box)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	output, buildErr := buildProject(ctx.box, system)
+	metrics := RunMetrics{CompileMs: elapsedMs(start), Compiler: system.Name}
+	if buildErr != nil {
+		ctx.write(map[string]interface{}{
+			"type":        "compile_error",
+			"stderr":      output,
+			"diagnostics": parseDiagnostics(output),
+		})
+		return buildErr
+	}
+
+	ctx.write(map[string]interface{}{
+		"type":        "compile_success",
+		"stdout":      output,
+		"diagnostics": parseDiagnostics(output),
+		"build":       system.Name,
+	})
+
+	runCmd, err := resolveProjectRun(ctx.box, system, manifest, before)
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	// 프로젝트 모드는 sanitizer 빌드를 쓰지 않으므로 리포트에 붙일 소스가 없다.
+	option.ExecuteCmd = runCmd
+	if err := runInteractive(ctx, option, "", params, metrics); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readProjectManifest(box *Box) (projectManifestFile, error) {
+	data, err := box.readFile(filepath.Join(box.Workspace, projectManifest), projectManifestLimit)
+	if errors.Is(err, os.ErrNotExist) {
+		return projectManifestFile{}, nil
+	}
+	if err != nil {
+		return projectManifestFile{}, err
+	}
+	return parseProjectManifest(data)
+}
+
+func parseProjectManifest(data []byte) (projectManifestFile, error) {
+	var manifest projectManifestFile
+	err := json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+func detectBuildSystem(box *Box, name string) (BuildSystem, error) {
+	if name != "" {
+		for _, system := range BuildSystems {
+			if system.Name == name {
+				return system, nil
+			}
+		}
+		return BuildSystem{}, fmt.Errorf("unknown build system in %s: %s", projectManifest, name)
+	}
+
+	for _, system := range BuildSystems {
+		for _, marker := range system.Markers {
+			if _, err := os.Stat(filepath.Join(box.Workspace, marker)); err == nil {
+				return system, nil
+			}
+		}
+		if system.SourceExt != "" {
+			if sources, err := workspaceSources(box, system.SourceExt); err == nil && len(sources) > 0 {
+				return system, nil
+			}
+		}
+	}
+	return BuildSystem{}, fmt.Errorf("no build system detected: add a Makefile, CMakeLists.txt, go.mod, Java sources or %s", projectManifest)
+}
+
+func buildProject(box *Box, system BuildSystem) (string, error) {
+	output := &limitedBuffer{limit: projectBuildOutputLimit}
+
+	for i, buildCmd := range system.BuildCmds {
+		buildCmd = append([]string{}, buildCmd...)
+		if i == len(system.BuildCmds)-1 && system.SourceExt != "" {
+			sources, err := workspaceSources(box, system.SourceExt)
+			if err != nil {
+				return output.String(), err
+			}
+			buildCmd = append(buildCmd, sources...)
+		}
+
+		args := box.isolateArgs()
+		args = append(args, projectBuildArgs...)
+		args = append(args, "--silent", "--run", "--")
+		args = append(args, buildCmd...)
+
+		cmd := exec.Command(isolateBinary, args...)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		if err := cmd.Run(); err != nil {
+			return output.String(), fmt.Errorf("%s failed: %w", filepath.Base(buildCmd[0]), err)
+		}
+	}
+	return output.String(), nil
+}
+
+func resolveProjectRun(box *Box, system BuildSystem, manifest projectManifestFile, before map[string]bool) ([]string, error) {
+	if len(manifest.Run) > 0 {
+		program, err := resolveProjectProgram(manifest.Run[0])
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{program}, manifest.Run[1:]...), nil
+	}
+
+	for _, candidate := range system.DefaultRun {
+		if info, err := os.Stat(box.path(candidate[0])); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	// 관례에 맞는 실행 파일이 없으면 빌드로 새로 생긴 실행 파일이 하나뿐일 때 그것을 쓴다.
+	after, err := executableFiles(box)
+	if err != nil {
+		return nil, err
+	}
+	var created []string
+	for path := range after {
+		if !before[path] {
+			created = append(created, path)
+		}
+	}
+	if len(created) == 1 {
+		return []string{created[0]}, nil
+	}
+	return nil, fmt.Errorf("cannot determine the executable (%d candidates): set \"run\" in %s", len(created), projectManifest)
+}
+
+// manifest 의 실행 파일은 workspace 안의 파일이거나 /usr/bin, /bin 의 프로그램이어야 한다.
+func resolveProjectProgram(name string) (string, error) {
+	if !strings.Contains(name, "/") {
+		for _, dir := range []string{"/usr/bin", "/bin"} {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		return "", fmt.Errorf("program not found: %s", name)
+	}
+
+	if filepath.IsAbs(name) {
+		cleaned := filepath.Clean(name)
+		for _, dir := range []string{workspaceDir, "/usr/bin", "/bin"} {
+			if strings.HasPrefix(cleaned, dir+"/") {
+				return cleaned, nil
+			}
+		}
+		return "", fmt.Errorf("program outside the workspace: %s", name)
+	}
+
+	return workspacePath(strings.TrimPrefix(name, "./"))
+}
+
+// executableFiles 는 샌드박스에서 보이는 경로로 돌려준다.
+func executableFiles(box *Box) (map[string]bool, error) {
+	files := map[string]bool{}
+	err := filepath.WalkDir(box.Workspace, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// cmake 가 만드는 검사용 바이너리는 후보에서 뺀다.
+		if d.IsDir() && d.Name() == "CMakeFiles" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&0o111 != 0 {
+			rel, _ := filepath.Rel(box.Workspace, path)
+			files[filepath.Join(workspaceDir, rel)] = true
+		}
+		return nil
+	})
+	return files, err
+}
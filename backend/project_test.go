@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseProjectManifest(t *testing.T) {
+	manifest, err := parseProjectManifest([]byte(`{"build":"make","run":["./bin/app","--verbose"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := projectManifestFile{Build: "make", Run: []string{"./bin/app", "--verbose"}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("manifest = %+v, want %+v", manifest, want)
+	}
+
+	if _, err := parseProjectManifest([]byte(`{"run":"main"}`)); err == nil {
+		t.Error("run must be a list")
+	}
+	if _, err := parseProjectManifest([]byte(`{`)); err == nil {
+		t.Error("truncated manifest was accepted")
+	}
+}
+
+func TestReadProjectManifest(t *testing.T) {
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	if manifest, err := readProjectManifest(box); err != nil || !reflect.DeepEqual(manifest, projectManifestFile{}) {
+		t.Fatalf("missing manifest = %+v, %v", manifest, err)
+	}
+
+	path := filepath.Join(box.Workspace, projectManifest)
+	if err := os.WriteFile(path, []byte(`{"build":"go"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, err := readProjectManifest(box); err != nil || manifest.Build != "go" {
+		t.Fatalf("manifest = %+v, %v", manifest, err)
+	}
+
+	// 사용자 프로그램이 만든 symlink 를 따라가서 러너의 파일을 읽으면 안 된다.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/hostname", path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readProjectManifest(box); err == nil {
+		t.Error("symlinked manifest was read")
+	}
+}
+
+func TestDetectBuildSystemByName(t *testing.T) {
+	box := &Box{ID: sessionBoxBase, Workspace: t.TempDir()}
+	system, err := detectBuildSystem(box, "cmake")
+	if err != nil || system.Name != "cmake" {
+		t.Fatalf("detectBuildSystem(cmake) = %+v, %v", system, err)
+	}
+	if _, err := detectBuildSystem(box, "bazel"); err == nil {
+		t.Error("unknown build system was accepted")
+	}
+}
+
+func TestResolveProjectProgram(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "./bin/app", want: "/code/bin/app"},
+		{name: "bin/app", want: "/code/bin/app"},
+		{name: "/code/build/main", want: "/code/build/main"},
+		{name: "/usr/bin/python3", want: "/usr/bin/python3"},
+		{name: "/code/../etc/passwd", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: "../outside", wantErr: true},
+		{name: "no-such-program-iris", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProjectProgram(tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveProjectProgram(%q) = %q, want error", tt.name, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveProjectProgram(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
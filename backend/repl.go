@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	replDefaultTimeout = 10 * time.Second
+	replMaxTimeout     = 60 * time.Second
+	replMaxSource      = 256 * 1024
+)
+
+// 세션 전체에 걸리는 제한이다. 셀별 제한은 러너의 타이머로 건다.
+// node 는 스레드를 여러 개 쓰므로 --processes 가 필요하다.
+var replSessionArgs = []string{
+	"--processes",
+	"--time=300",
+	"--wall-time=3600",
+	"--env=HOME=/box",
+}
+
+// replSession 은 repl 모드에서 ReplCmd 드라이버를 살려 두고 eval 요청을 한 번에 하나씩 넘긴다.
+// 드라이버는 stdin 으로 {"id", "code"} 를 한 줄씩 받고 셀마다 결과를 한 줄의 JSON 으로 출력한다.
+type replSession struct {
+	ctx    *ConnectionContext
+	option CompileOption
+	params RunParams
+	images *imageWatcher
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	done    chan struct{}
+	gen     int
+	nextID  int
+	pending *replCell
+	closed  bool
+}
+
+type replCell struct {
+	id    int
+	cell  string
+	timer *time.Timer
+}
+
+type replResult struct {
+	ID        int     `json:"id"`
+	Stdout    string  `json:"stdout"`
+	Stderr    string  `json:"stderr"`
+	Value     *string `json:"value"`
+	Error     *string `json:"error"`
+	Traceback *string `json:"traceback"`
+}
+
+func startReplSession(ctx *ConnectionContext, option CompileOption, params RunParams) error {
+	images, err := startImageWatcher(ctx)
+	if err != nil {
+		return err
+	}
+
+	session := &replSession{ctx: ctx, option: option, params: params, images: images}
+	session.mu.Lock()
+	err = session.start()
+	session.mu.Unlock()
+	if err != nil {
+		images.close()
+		return err
+	}
+
+	ctx.setReplSession(session)
+	ctx.write(map[string]interface{}{"type": "repl_ready"})
+	return nil
+}
+
+// start 는 s.mu 를 잡은 상태에서 호출한다.
+func (s *replSession) start() error {
+	args := s.ctx.box.isolateArgs()
+	args = append(args, s.option.IsolateArgs...)
+	args = append(args, replSessionArgs...)
+	args = append(args, imageIsolateArgs...)
+	args = append(args, s.params.isolateArgs()...)
+	args = append(args, "--silent", "--run", "--")
+	args = append(args, s.option.ReplCmd...)
+
+	cmd := exec.Command(isolateBinary, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &limitedBuffer{limit: 4 * 1024}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.gen++
+	s.cmd = cmd
+	s.stdin = stdin
+	s.done = make(chan struct{})
+	// stdin 은 제어 채널이라 input 메시지로 쓰지 않는다.
+	s.ctx.setProcess(cmd, nil)
+
+	go s.readResults(s.gen, stdout)
+	go s.wait(s.gen, cmd, s.done, stderr)
+	return nil
+}
+
+func (s *replSession) eval(msg *Message) {
+	if len(msg.Source) > replMaxSource {
+		s.writeError(msg.Cell, fmt.Sprintf("cell exceeds %d bytes", replMaxSource), "")
+		return
+	}
+	timeout := replDefaultTimeout
+	if msg.Timeout > 0 {
+		timeout = time.Duration(msg.Timeout * float64(time.Second))
+	}
+	if timeout > replMaxTimeout {
+		timeout = replMaxTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.writeError(msg.Cell, "repl session is closed", "")
+		return
+	}
+	if s.cmd == nil {
+		s.writeError(msg.Cell, "repl session is restarting", "")
+		return
+	}
+	if s.pending != nil {
+		s.writeError(msg.Cell, "another cell is still running", "")
+		return
+	}
+
+	s.nextID++
+	cell := &replCell{id: s.nextID, cell: msg.Cell}
+	request, _ := json.Marshal(map[string]interface{}{"id": cell.id, "code": msg.Source})
+	if _, err := s.stdin.Write(append(request, '\n')); err != nil {
+		s.writeError(msg.Cell, fmt.Sprintf("failed to send cell: %v", err), "")
+		return
+	}
+
+	gen := s.gen
+	cell.timer = time.AfterFunc(timeout, func() { s.timeout(gen, cell.id) })
+	s.pending = cell
+}
+
+// takePending 은 gen, id 가 현재 셀과 같을 때만 셀을 꺼낸다. s.mu 를 잡은 상태에서 호출한다.
+func (s *replSession) takePending(gen int, id int) *replCell {
+	if s.closed || gen != s.gen || s.pending == nil || s.pending.id != id {
+		return nil
+	}
+	cell := s.pending
+	s.pending = nil
+	cell.timer.Stop()
+	return cell
+}
+
+func (s *replSession) readResults(gen int, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var result replResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			log.Println("repl driver output error:", err)
+			continue
+		}
+
+		s.mu.Lock()
+		cell := s.takePending(gen, result.ID)
+		s.mu.Unlock()
+		if cell == nil {
+			continue
+		}
+
+		event := map[string]interface{}{
+			"type":   "repl_result",
+			"cell":   cell.cell,
+			"stdout": result.Stdout,
+			"stderr": result.Stderr,
+		}
+		if result.Error != nil {
+			event["type"] = "repl_error"
+			event["error"] = *result.Error
+			if result.Traceback != nil {
+				event["traceback"] = *result.Traceback
+			}
+		} else if result.Value != nil {
+			event["value"] = *result.Value
+		}
+		s.ctx.write(event)
+	}
+}
+
+// 셀 시간 초과는 인터프리터를 중단할 방법이 없으므로 세션을 새로 띄운다. 이전 상태는 사라진다.
+func (s *replSession) timeout(gen int, id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cell := s.takePending(gen, id)
+	if cell == nil {
+		return
+	}
+	s.writeError(cell.cell, "cell timed out", "")
+	s.restart("timeout")
+}
+
+func (s *replSession) wait(gen int, cmd *exec.Cmd, done chan struct{}, stderr *limitedBuffer) {
+	_ = cmd.Wait()
+	close(done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || gen != s.gen {
+		return
+	}
+
+	// 실행 중인 셀이 인터프리터를 죽였으면 그 셀의 오류로 알리고 새로 띄운다.
+	if s.pending != nil {
+		cell := s.takePending(gen, s.pending.id)
+		s.writeError(cell.cell, "interpreter exited", stderr.String())
+		s.restart("exited")
+		return
+	}
+
+	s.closed = true
+	go func() {
+		s.images.close()
+		s.ctx.write(map[string]interface{}{
+			"type":        "exit",
+			"return_code": -1,
+			"error":       "repl interpreter exited",
+		})
+		s.ctx.finishRun()
+	}()
+}
+
+func (s *replSession) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.pending != nil {
+		cell := s.takePending(s.gen, s.pending.id)
+		s.writeError(cell.cell, "cancelled by reset", "")
+	}
+	s.restart("reset")
+}
+
+// restart 는 s.mu 를 잡은 상태에서 호출한다.
+func (s *replSession) restart(reason string) {
+	s.kill()
+	// 기다리는 동안 닫혔거나 다른 goroutine 이 이미 새로 띄웠을 수 있다.
+	if s.closed || s.cmd != nil {
+		return
+	}
+	if err := s.start(); err != nil {
+		s.closed = true
+		go s.images.close()
+		s.ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": fmt.Sprintf("failed to restart repl: %v", err),
+		})
+		return
+	}
+	s.ctx.write(map[string]interface{}{
+		"type":   "repl_reset",
+		"reason": reason,
+	})
+}
+
+// kill 은 같은 box 에서 새 프로세스를 띄우기 전에 이전 프로세스가 끝날 때까지 기다린다.
+// 기다리는 동안에는 s.mu 를 놓는다.
+func (s *replSession) kill() {
+	if s.cmd != nil {
+		_ = s.stdin.Close()
+		if s.cmd.Process != nil {
+			_ = s.cmd.Process.Kill()
+		}
+		s.cmd = nil
+		s.gen++
+	}
+	done := s.done
+	s.mu.Unlock()
+	<-done
+	s.mu.Lock()
+}
+
+func (s *replSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	// 셀이 끝난 뒤에 저장된 그림도 보내야 하므로 감시는 세션이 끝날 때까지 계속한다.
+	s.images.close()
+	if s.pending != nil {
+		s.pending.timer.Stop()
+		s.pending = nil
+	}
+	if s.cmd != nil {
+		_ = s.stdin.Close()
+		if s.cmd.Process != nil {
+			_ = s.cmd.Process.Kill()
+		}
+	}
+}
+
+func (s *replSession) writeError(cell string, message string, traceback string) {
+	event := map[string]interface{}{
+		"type":  "repl_error",
+		"cell":  cell,
+		"error": message,
+	}
+	if traceback != "" {
+		event["traceback"] = traceback
+	}
+	s.ctx.write(event)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplReadResults(t *testing.T) {
+	client, ctx := serveTestConnection(t)
+	s := &replSession{ctx: ctx}
+	s.pending = &replCell{id: 1, cell: "c1", timer: time.NewTimer(time.Hour)}
+
+	// 지금 셀이 아닌 id 의 결과와 깨진 줄은 버린다.
+	s.readResults(0, strings.NewReader("not json\n"+
+		`{"id":7,"stdout":"stale","value":"0"}`+"\n"+
+		`{"id":1,"stdout":"hi\n","stderr":"","value":"2"}`+"\n"))
+	event := readEvent(t, client)
+	if event["type"] != "repl_result" || event["cell"] != "c1" || event["value"] != "2" || event["stdout"] != "hi\n" {
+		t.Fatalf("event = %v", event)
+	}
+	if s.pending != nil {
+		t.Fatal("cell is still pending")
+	}
+
+	s.pending = &replCell{id: 2, cell: "c2", timer: time.NewTimer(time.Hour)}
+	s.readResults(0, strings.NewReader(`{"id":2,"stdout":"","stderr":"","value":null,"error":"NameError: x","traceback":"Traceback..."}`+"\n"))
+	event = readEvent(t, client)
+	if event["type"] != "repl_error" || event["cell"] != "c2" || event["error"] != "NameError: x" || event["traceback"] != "Traceback..." {
+		t.Fatalf("event = %v", event)
+	}
+}
+
+// 드라이버 프로토콜은 이미지와 같은 python3 가 있으면 직접 실행해서 확인한다.
+func TestPyReplDriver(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	cmd := exec.Command(python, "tools/pyrepl.py")
+	cmd.Stdin = strings.NewReader(`{"id": 1, "code": "x = 40\nprint('hi')\nx + 2"}` + "\n" +
+		`{"id": 2, "code": "raise SystemExit(3)"}` + "\n" +
+		`{"id": 3, "code": "x"}` + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []replResult
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		var result replResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("invalid driver output %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].Value == nil || *results[0].Value != "42" || results[0].Stdout != "hi\n" {
+		t.Errorf("cell 1 = %+v", results[0])
+	}
+	if results[1].Error == nil || !strings.HasPrefix(*results[1].Error, "SystemExit") {
+		t.Errorf("cell 2 = %+v", results[1])
+	}
+	// SystemExit 뒤에도 세션 상태가 남아 있어야 한다.
+	if results[2].Value == nil || *results[2].Value != "40" {
+		t.Errorf("cell 3 = %+v", results[2])
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// pod manager 는 세션이 모두 끝난 pod 을 풀에 돌려놓기 전에 /reset 을 호출한다.
+// 러너는 box 를 처음 상태로 되돌린 뒤 남은 프로세스, 파일, 디스크와 메모리를 확인해서
+// 하나라도 실패하면 dirty 로 알리고, manager 는 그 pod 을 새 pod 으로 바꾼다.
+const (
+	// manager 는 프록시 연결이 끝나자마자 /reset 을 부르므로, 세션의 box 가 정리되어 풀에 돌아올 때까지
+	// (wsHandler 의 artifacts.Wait 와 isolate --cleanup) 이만큼 기다린다.
+	resetReleaseWait = 15 * time.Second
+)
+
+var (
+	resetMinFreeDisk   = int64(envInt("IRIS_RESET_MIN_DISK_MB", 256)) << 20
+	resetMinFreeMemory = int64(envInt("IRIS_RESET_MIN_MEMORY_MB", 128)) << 20
+)
+
+type resetCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type resetReport struct {
+	Clean  bool         `json:"clean"`
+	Checks []resetCheck `json:"checks"`
+}
+
+func (r *resetReport) add(name string, detail string, err error) {
+	check := resetCheck{Name: name, OK: err == nil, Detail: detail}
+	if err != nil {
+		check.Detail = err.Error()
+		r.Clean = false
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+func resetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, resetRunner())
+}
+
+// resetRunner 는 빈 box 를 모두 풀에서 빼서 정리하고 확인한 뒤 돌려준다.
+// 기다린 뒤에도 쓰고 있는 box 는 건드리지 않고, manager 가 빈 pod 이라고 생각한 것과 다르므로 dirty 로 본다.
+func resetRunner() resetReport {
+	report := resetReport{Clean: true}
+
+	boxes.waitIdle(resetReleaseWait)
+	free, busy := boxes.takeFree()
+	if busy > 0 {
+		report.add("sessions", "", fmt.Errorf("%d session(s) still running", busy))
+	} else {
+		report.add("sessions", "", nil)
+	}
+
+	for _, box := range free {
+		detail, err := resetBox(box)
+		report.add(fmt.Sprintf("box %d", box.ID), detail, err)
+		boxes.put(box)
+	}
+
+	report.add("disk", "", checkFreeDisk(boxWorkspaceRoot, resetMinFreeDisk))
+	report.add("memory", "", checkFreeMemory(resetMinFreeMemory))
+	return report
+}
+
+// resetBox 는 남은 프로세스를 죽이고 box 를 다시 만든 뒤 비어 있는지 확인한다.
+// 정리해서 되돌린 것은 detail 로만 알리고, 되돌리지 못한 것만 오류로 본다.
+func resetBox(box *Box) (string, error) {
+	uid := isolateFirstUID + box.ID
+	killed, err := killUserProcesses(uid)
+	if err != nil {
+		return "", fmt.Errorf("failed to kill processes: %w", err)
+	}
+	var detail string
+	if killed > 0 {
+		detail = fmt.Sprintf("killed %d stray process(es)", killed)
+	}
+	if err := box.cleanup(); err != nil {
+		return detail, fmt.Errorf("failed to clean up: %w", err)
+	}
+	if err := box.init(); err != nil {
+		return detail, fmt.Errorf("failed to init: %w", err)
+	}
+
+	// 정리가 끝난 뒤에도 남아 있으면 죽일 수 없는 프로세스다.
+	if pids, err := userProcesses(uid); err != nil {
+		return detail, err
+	} else if len(pids) > 0 {
+		return detail, fmt.Errorf("%d process(es) survived cleanup", len(pids))
+	}
+	for _, dir := range []string{box.Workspace, filepath.Join(isolateBoxRoot, box.isolateID(), "box")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return detail, err
+		}
+		if len(entries) > 0 {
+			return detail, fmt.Errorf("%s is not empty after reset", dir)
+		}
+	}
+	return detail, nil
+}
+
+// killUserProcesses 는 box 사용자로 실행 중인 프로세스를 모두 죽인다.
+func killUserProcesses(uid int) (int, error) {
+	pids, err := userProcesses(uid)
+	if err != nil {
+		return 0, err
+	}
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return len(pids), nil
+}
+
+func userProcesses(uid int) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if processUID(pid) == uid {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// processUID 는 /proc/<pid>/status 의 실제 uid 를 읽는다. 이미 끝난 프로세스는 -1 이다.
+func processUID(pid int) int {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	return parseStatusUID(f)
+}
+
+func parseStatusUID(r io.Reader) int {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Uid:" {
+			uid, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return -1
+			}
+			return uid
+		}
+	}
+	return -1
+}
+
+func checkFreeDisk(path string, need int64) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return err
+	}
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	if free < need {
+		return fmt.Errorf("%d bytes free on %s, need %d", free, path, need)
+	}
+	return nil
+}
+
+// checkFreeMemory 는 컨테이너 메모리 제한 기준으로 남은 메모리를 본다.
+// cgroup v2 의 제한을 읽을 수 없으면 /proc/meminfo 의 MemAvailable 을 쓴다.
+func checkFreeMemory(need int64) error {
+	free, err := cgroupFreeMemory()
+	if err != nil {
+		free, err = meminfoAvailable()
+		if err != nil {
+			return err
+		}
+	}
+	if free < need {
+		return fmt.Errorf("%d bytes of memory available, need %d", free, need)
+	}
+	return nil
+}
+
+func cgroupFreeMemory() (int64, error) {
+	limit, err := readCgroupValue("/sys/fs/cgroup/memory.max")
+	if err != nil {
+		return 0, err
+	}
+	current, err := readCgroupValue("/sys/fs/cgroup/memory.current")
+	if err != nil {
+		return 0, err
+	}
+	return limit - current, nil
+}
+
+func readCgroupValue(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, fmt.Errorf("%s is unlimited", path)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func meminfoAvailable() (int64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMeminfoAvailable(f)
+}
+
+func parseMeminfoAvailable(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb << 10, nil
+		}
+	}
+	return 0, fmt.Errorf("MemAvailable not found in meminfo")
+}
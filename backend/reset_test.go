@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusUID(t *testing.T) {
+	status := "Name:\tpython3\nState:\tS (sleeping)\nUid:\t60002\t60002\t60002\t60002\nGid:\t60002\t60002\t60002\t60002\n"
+	if uid := parseStatusUID(strings.NewReader(status)); uid != 60002 {
+		t.Errorf("uid = %d, want 60002", uid)
+	}
+	if uid := parseStatusUID(strings.NewReader("Name:\tzombie\n")); uid != -1 {
+		t.Errorf("uid without a Uid line = %d, want -1", uid)
+	}
+	if uid := parseStatusUID(strings.NewReader("Uid:\tx\n")); uid != -1 {
+		t.Errorf("invalid uid = %d, want -1", uid)
+	}
+	if uid := processUID(os.Getpid()); uid != os.Getuid() {
+		t.Errorf("processUID(self) = %d, want %d", uid, os.Getuid())
+	}
+}
+
+func TestParseMeminfoAvailable(t *testing.T) {
+	meminfo := "MemTotal:       16318424 kB\nMemFree:         1234567 kB\nMemAvailable:    8000000 kB\n"
+	available, err := parseMeminfoAvailable(strings.NewReader(meminfo))
+	if err != nil || available != 8000000<<10 {
+		t.Errorf("available = %d, %v", available, err)
+	}
+	if _, err := parseMeminfoAvailable(strings.NewReader("MemTotal: 1 kB\n")); err == nil {
+		t.Error("meminfo without MemAvailable was accepted")
+	}
+	if _, err := parseMeminfoAvailable(strings.NewReader("MemAvailable: lots kB\n")); err == nil {
+		t.Error("invalid MemAvailable was accepted")
+	}
+}
+
+func TestResetReport(t *testing.T) {
+	report := resetReport{Clean: true}
+	report.add("disk", "", nil)
+	if !report.Clean || !report.Checks[0].OK {
+		t.Fatalf("report = %+v", report)
+	}
+	report.add("box 2", "killed 1 stray process(es)", os.ErrNotExist)
+	if report.Clean || report.Checks[1].OK || report.Checks[1].Detail != os.ErrNotExist.Error() {
+		t.Fatalf("report = %+v", report)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	runMaxArgs     = 64
+	runMaxArgLen   = 4096
+	runMaxEnv      = 32
+	runMaxEnvValue = 4096
+)
+
+// RunParams 는 code 메시지에서 받은 실행별 설정이다. 실행 명령 뒤에 붙는 인자,
+// isolate 로 넘길 환경 변수, 대화형 입력 대신 연결할 workspace 의 stdin 파일,
+// 미리보기로 열어 줄 프로그램의 port 를 담는다.
+type RunParams struct {
+	Args      []string
+	Env       map[string]string
+	StdinFile string
+	Port      int
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,63}$`)
+
+// 사용자가 설정할 수 있는 환경 변수는 이 목록에 있는 이름뿐이다. 로더, libc, 런타임이 읽는 변수는
+// 이름이 계속 늘어나므로 막을 이름을 나열하지 않고 허용할 이름만 둔다.
+// 과제에 필요한 변수는 IRIS_ENV_ALLOWLIST 에 쉼표로 구분해서 더한다.
+var envAllowlist = newEnvAllowlist(os.Getenv("IRIS_ENV_ALLOWLIST"))
+
+func newEnvAllowlist(extra string) map[string]bool {
+	allowed := map[string]bool{
+		"LANG":     true,
+		"LC_ALL":   true,
+		"LC_CTYPE": true,
+		"TZ":       true,
+		"TERM":     true,
+		"USER":     true,
+		"LOGNAME":  true,
+		"COLUMNS":  true,
+		"LINES":    true,
+	}
+	for _, name := range strings.Split(extra, ",") {
+		if name = strings.TrimSpace(name); envNamePattern.MatchString(name) {
+			allowed[name] = true
+		}
+	}
+	return allowed
+}
+
+func validateRunParams(msg *Message) (RunParams, error) {
+	params := RunParams{}
+
+	if len(msg.Args) > runMaxArgs {
+		return params, fmt.Errorf("too many arguments: %d > %d", len(msg.Args), runMaxArgs)
+	}
+	for i, arg := range msg.Args {
+		if len(arg) > runMaxArgLen {
+			return params, fmt.Errorf("argument %d exceeds %d bytes", i, runMaxArgLen)
+		}
+		if strings.ContainsRune(arg, 0) {
+			return params, fmt.Errorf("argument %d contains a NUL byte", i)
+		}
+	}
+	params.Args = msg.Args
+
+	if len(msg.Env) > runMaxEnv {
+		return params, fmt.Errorf("too many environment variables: %d > %d", len(msg.Env), runMaxEnv)
+	}
+	for name, value := range msg.Env {
+		if !envAllowed(name) {
+			return params, fmt.Errorf("environment variable is not allowed: %s", name)
+		}
+		if len(value) > runMaxEnvValue || strings.ContainsRune(value, 0) {
+			return params, fmt.Errorf("invalid value for environment variable %s", name)
+		}
+	}
+	params.Env = msg.Env
+
+	if msg.StdinFile != "" {
+		path, err := workspacePath(msg.StdinFile)
+		if err != nil {
+			return params, fmt.Errorf("invalid stdin file: %w", err)
+		}
+		params.StdinFile = path
+	}
+
+	if msg.Port != 0 {
+		// box 사용자는 1024 미만 port 에 bind 할 수 없다.
+		if msg.Port < previewMinPort || msg.Port > 65535 {
+			return params, fmt.Errorf("port must be between %d and 65535", previewMinPort)
+		}
+		params.Port = msg.Port
+	}
+
+	return params, nil
+}
+
+func envAllowed(name string) bool {
+	return envAllowlist[name]
+}
+
+// isolateArgs 는 환경 변수를 이름 순으로 --env 인자로 만든다.
+func (p RunParams) isolateArgs() []string {
+	names := make([]string, 0, len(p.Env))
+	for name := range p.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		args = append(args, "--env="+name+"="+p.Env[name])
+	}
+	return args
+}
+
+// openStdinFile 은 workspace 안의 일반 파일만 연다. 경로의 어느 부분이든 심볼릭 링크이면 거절한다.
+func (p RunParams) openStdinFile(box *Box) (*os.File, error) {
+	f, err := box.openFile(box.path(p.StdinFile), unix.O_RDONLY|unix.O_NONBLOCK, 0)
+	if errors.Is(err, unix.ELOOP) {
+		return nil, fmt.Errorf("stdin file is not a regular file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stdin file not found: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("stdin file is not a regular file")
+	}
+	return f, nil
+}
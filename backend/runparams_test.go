@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateRunParams(t *testing.T) {
+	params, err := validateRunParams(&Message{
+		Args:      []string{"-n", "3"},
+		Env:       map[string]string{"TZ": "UTC", "LANG": "C.UTF-8"},
+		StdinFile: "input/1.txt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.StdinFile != "/code/input/1.txt" {
+		t.Errorf("StdinFile = %q", params.StdinFile)
+	}
+	want := []string{"--env=LANG=C.UTF-8", "--env=TZ=UTC"}
+	if got := params.isolateArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("isolateArgs() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"too many args", Message{Args: make([]string, runMaxArgs+1)}},
+		{"long arg", Message{Args: []string{strings.Repeat("a", runMaxArgLen+1)}}},
+		{"nul in arg", Message{Args: []string{"a\x00b"}}},
+		{"loader env", Message{Env: map[string]string{"LD_PRELOAD": "/code/evil.so"}}},
+		{"unlisted env", Message{Env: map[string]string{"MY_FLAG": "1"}}},
+		{"nul in env", Message{Env: map[string]string{"TZ": "UTC\x00"}}},
+		{"stdin outside workspace", Message{StdinFile: "../etc/passwd"}},
+		{"absolute stdin", Message{StdinFile: "/etc/passwd"}},
+		{"privileged port", Message{Port: 80}},
+		{"port out of range", Message{Port: 70000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validateRunParams(&tt.msg); err == nil {
+				t.Fatal("invalid params were accepted")
+			}
+		})
+	}
+}
+
+func TestNewEnvAllowlist(t *testing.T) {
+	allowed := newEnvAllowlist("MY_FLAG, DATA_DIR,lower,LD_PRELOAD=1,")
+	for _, name := range []string{"MY_FLAG", "DATA_DIR", "TZ"} {
+		if !allowed[name] {
+			t.Errorf("%s is not allowed", name)
+		}
+	}
+	for _, name := range []string{"lower", "LD_PRELOAD=1", "LD_PRELOAD", ""} {
+		if allowed[name] {
+			t.Errorf("%q is allowed", name)
+		}
+	}
+}
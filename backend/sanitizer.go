@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sanitizer 리포트 수집을 위해 보관하는 stderr 최대 크기
+const sanitizerStderrLimit = 256 * 1024
+
+type SanitizerFrame struct {
+	Index    int    `json:"index"`
+	PC       string `json:"pc"`
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Module   string `json:"module,omitempty"`
+}
+
+type SanitizerReport struct {
+	Sanitizer  string           `json:"sanitizer"`
+	Kind       string           `json:"kind"`
+	Message    string           `json:"message"`
+	File       string           `json:"file,omitempty"`
+	Line       int              `json:"line,omitempty"`
+	Column     int              `json:"column,omitempty"`
+	SourceLine string           `json:"source_line,omitempty"`
+	Frames     []SanitizerFrame `json:"frames"`
+}
+
+var (
+	// ==123==ERROR: AddressSanitizer: heap-buffer-overflow on address ...
+	asanHeaderRe = regexp.MustCompile(`^==\d+==ERROR: (\w+Sanitizer): ([\w-]+)\s*(.*)$`)
+	// /code/main.c:4:7: runtime error: signed integer overflow: ...
+	ubsanHeaderRe = regexp.MustCompile(`^(.+?):(\d+):(\d+): runtime error: (.*)$`)
+	// #0 0x55d5c1 in main /code/main.c:5:3
+	frameSourceRe = regexp.MustCompile(`^\s*#(\d+) (0x[0-9a-f]+) in (.+) (/[^ ]+?):(\d+)(?::(\d+))?$`)
+	// #1 0x7f12 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x29d90)
+	frameModuleRe = regexp.MustCompile(`^\s*#(\d+) (0x[0-9a-f]+)(?: in (.+?))?\s+\(([^)]+)\)$`)
+	summaryRe     = regexp.MustCompile(`^SUMMARY: `)
+)
+
+// parseSanitizerReports 는 ASan/UBSan 이 stderr 에 남긴 리포트를 구조화한다.
+// source 는 사용자 코드로, 리포트 위치의 코드 한 줄을 함께 돌려주기 위해 사용한다.
+func parseSanitizerReports(stderr string, sourceFile string, source string) []SanitizerReport {
+	var reports []SanitizerReport
+	var current *SanitizerReport
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		resolveReportLocation(current, sourceFile, source)
+		reports = append(reports, *current)
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	scanner.Buffer(make([]byte, 64*1024), sanitizerStderrLimit)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := asanHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &SanitizerReport{
+				Sanitizer: m[1],
+				Kind:      m[2],
+				Message:   strings.TrimSpace(m[2] + " " + m[3]),
+				Frames:    []SanitizerFrame{},
+			}
+			continue
+		}
+
+		if m := ubsanHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			current = &SanitizerReport{
+				Sanitizer: "UndefinedBehaviorSanitizer",
+				Kind:      ubsanKind(m[4]),
+				Message:   m[4],
+				File:      m[1],
+				Line:      lineNo,
+				Column:    column,
+				Frames:    []SanitizerFrame{},
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if m := frameSourceRe.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			lineNo, _ := strconv.Atoi(m[5])
+			column, _ := strconv.Atoi(m[6])
+			current.Frames = append(current.Frames, SanitizerFrame{
+				Index:    index,
+				PC:       m[2],
+				Function: m[3],
+				File:     m[4],
+				Line:     lineNo,
+				Column:   column,
+			})
+			continue
+		}
+
+		if m := frameModuleRe.FindStringSubmatch(line); m != nil {
+			index, _ := strconv.Atoi(m[1])
+			current.Frames = append(current.Frames, SanitizerFrame{
+				Index:    index,
+				PC:       m[2],
+				Function: m[3],
+				Module:   m[4],
+			})
+			continue
+		}
+
+		if summaryRe.MatchString(line) {
+			flush()
+		}
+	}
+	flush()
+
+	return reports
+}
+
+// 첫 번째로 사용자 파일을 가리키는 프레임을 리포트 위치로 사용한다.
+func resolveReportLocation(report *SanitizerReport, sourceFile string, source string) {
+	if report.File == "" {
+		for _, frame := range report.Frames {
+			if frame.File == sourceFile {
+				report.File = frame.File
+				report.Line = frame.Line
+				report.Column = frame.Column
+				break
+			}
+		}
+	}
+
+	if report.File != sourceFile || report.Line <= 0 {
+		return
+	}
+	lines := strings.Split(source, "\n")
+	if report.Line <= len(lines) {
+		report.SourceLine = strings.TrimRight(lines[report.Line-1], "\r")
+	}
+}
+
+func ubsanKind(message string) string {
+	switch {
+	case strings.HasPrefix(message, "signed integer overflow"):
+		return "signed-integer-overflow"
+	case strings.HasPrefix(message, "division by zero"), strings.HasPrefix(message, "integer divide by zero"):
+		return "integer-divide-by-zero"
+	case strings.HasPrefix(message, "shift exponent"), strings.HasPrefix(message, "left shift"):
+		return "shift"
+	case strings.HasPrefix(message, "index"):
+		return "bounds"
+	case strings.HasPrefix(message, "load of null pointer"), strings.HasPrefix(message, "member access within null pointer"):
+		return "null"
+	case strings.HasPrefix(message, "load of misaligned address"):
+		return "alignment"
+	case strings.HasPrefix(message, "execution reached the end of a value-returning function"):
+		return "return"
+	default:
+		return "undefined-behavior"
+	}
+}
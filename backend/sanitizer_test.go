@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSanitizerReportsASan(t *testing.T) {
+	stderr := `=================================================================
+==42==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000014 at pc 0x55d5c1 bp 0x7ffd sp 0x7ffd
+WRITE of size 4 at 0x602000000014 thread T0
+    #0 0x55d5c1 in main /code/main.c:5:10
+    #1 0x7f12 in __libc_start_main (/lib/x86_64-linux-gnu/libc.so.6+0x29d90)
+    #2 0x55d4ad in _start (/code/main+0x10ad)
+
+SUMMARY: AddressSanitizer: heap-buffer-overflow /code/main.c:5:10 in main
+==42==ABORTING
+`
+	source := "#include <stdlib.h>\n\nint main(void) {\n    int *a = malloc(4);\n    a[1] = 1;\n}\n"
+
+	reports := parseSanitizerReports(stderr, "/code/main.c", source)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1: %+v", len(reports), reports)
+	}
+	want := SanitizerReport{
+		Sanitizer:  "AddressSanitizer",
+		Kind:       "heap-buffer-overflow",
+		Message:    "heap-buffer-overflow on address 0x602000000014 at pc 0x55d5c1 bp 0x7ffd sp 0x7ffd",
+		File:       "/code/main.c",
+		Line:       5,
+		Column:     10,
+		SourceLine: "    a[1] = 1;",
+		Frames: []SanitizerFrame{
+			{Index: 0, PC: "0x55d5c1", Function: "main", File: "/code/main.c", Line: 5, Column: 10},
+			{Index: 1, PC: "0x7f12", Function: "__libc_start_main", Module: "/lib/x86_64-linux-gnu/libc.so.6+0x29d90"},
+			{Index: 2, PC: "0x55d4ad", Function: "_start", Module: "/code/main+0x10ad"},
+		},
+	}
+	if !reflect.DeepEqual(reports[0], want) {
+		t.Errorf("report = %+v\nwant %+v", reports[0], want)
+	}
+}
+
+func TestParseSanitizerReportsUBSan(t *testing.T) {
+	stderr := "/code/main.c:3:14: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'\n" +
+		"/code/main.c:4:12: runtime error: division by zero\n"
+	source := "int main(void) {\n    int x = 2147483647;\n    int y = x + 1;\n    return y / 0;\n}\n"
+
+	reports := parseSanitizerReports(stderr, "/code/main.c", source)
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2: %+v", len(reports), reports)
+	}
+	tests := []struct {
+		kind       string
+		line       int
+		sourceLine string
+	}{
+		{"signed-integer-overflow", 3, "    int y = x + 1;"},
+		{"integer-divide-by-zero", 4, "    return y / 0;"},
+	}
+	for i, tt := range tests {
+		got := reports[i]
+		if got.Sanitizer != "UndefinedBehaviorSanitizer" || got.Kind != tt.kind || got.Line != tt.line || got.SourceLine != tt.sourceLine {
+			t.Errorf("report %d = %+v, want kind %q line %d source %q", i, got, tt.kind, tt.line, tt.sourceLine)
+		}
+	}
+}
+
+func TestParseSanitizerReportsIgnoresOtherOutput(t *testing.T) {
+	stderr := "warning: something\n    #0 0x1 in main /code/main.c:1:1\nSUMMARY: nothing\n"
+	if reports := parseSanitizerReports(stderr, "/code/main.c", ""); len(reports) != 0 {
+		t.Errorf("got %+v, want no reports", reports)
+	}
+}
+
+func TestUbsanKind(t *testing.T) {
+	tests := map[string]string{
+		"signed integer overflow: 1 + 1":                          "signed-integer-overflow",
+		"integer divide by zero":                                  "integer-divide-by-zero",
+		"shift exponent 40 is too large":                          "shift",
+		"index 10 out of bounds for type 'int [5]'":               "bounds",
+		"load of null pointer of type 'int'":                      "null",
+		"load of misaligned address 0x1 for type 'int'":           "alignment",
+		"execution reached the end of a value-returning function": "return",
+		"something new": "undefined-behavior",
+	}
+	for message, want := range tests {
+		if got := ubsanKind(message); got != want {
+			t.Errorf("ubsanKind(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
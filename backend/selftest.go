@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 러너가 시작할 때 언어마다 hello world 를 세션과 같은 handleCode 경로로 컴파일하고 실행한다.
+// 이미지 빌드가 깨진 언어는 capabilities 와 /readyz 에 disabled 로 표시되고 code 요청을 거절한다.
+// 실행하면서 컴파일 캐시, javac 데몬, 페이지 캐시도 데워진다.
+const (
+	selfTestTimeout = 60 * time.Second
+	selfTestExpect  = "hello"
+)
+
+type selfTestProgram struct {
+	Source string
+	// 툴체인 버전을 출력하는 명령. 출력의 첫 줄을 쓴다.
+	VersionCmd []string
+	// SQL 처럼 결과를 stdout 이 아닌 이벤트로 보내는 언어는 종료 코드만 본다.
+	ExitOnly bool
+}
+
+var selfTestPrograms = map[string]selfTestProgram{
+	C: {
+		Source:     "#include <stdio.h>\n\nint main(void) {\n    printf(\"hello\\n\");\n    return 0;\n}\n",
+		VersionCmd: []string{"/usr/bin/gcc", "--version"},
+	},
+	CPP: {
+		Source:     "#include <bits/stdc++.h>\n\nint main() {\n    std::cout << \"hello\\n\";\n}\n",
+		VersionCmd: []string{"/usr/bin/g++", "--version"},
+	},
+	C_SANITIZER: {
+		Source:     "#include <stdio.h>\n\nint main(void) {\n    printf(\"hello\\n\");\n    return 0;\n}\n",
+		VersionCmd: []string{"/usr/bin/gcc", "--version"},
+	},
+	CPP_SANITIZER: {
+		Source:     "#include <bits/stdc++.h>\n\nint main() {\n    std::cout << \"hello\\n\";\n}\n",
+		VersionCmd: []string{"/usr/bin/g++", "--version"},
+	},
+	JAVA: {
+		Source:     "public class Main {\n    public static void main(String[] args) {\n        System.out.println(\"hello\");\n    }\n}\n",
+		VersionCmd: []string{"/usr/bin/javac", "-version"},
+	},
+	GO: {
+		Source:     "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n",
+		VersionCmd: []string{"/usr/bin/go", "version"},
+	},
+	PYTHON: {
+		Source:     "print(\"hello\")\n",
+		VersionCmd: []string{"/usr/bin/python3", "--version"},
+	},
+	SQL: {
+		Source:     "SELECT 'hello' AS greeting;\n",
+		VersionCmd: []string{"/usr/bin/python3", "-c", "import sqlite3; print('SQLite', sqlite3.sqlite_version)"},
+		ExitOnly:   true,
+	},
+	JAVASCRIPT: {
+		Source:     "console.log(\"hello\");\n",
+		VersionCmd: []string{"/usr/bin/node", "--version"},
+	},
+	RUST: {
+		Source:     "fn main() {\n    println!(\"hello\");\n}\n",
+		VersionCmd: []string{"/usr/bin/rustc", "--version"},
+	},
+	KOTLIN: {
+		Source:     "fun main() {\n    println(\"hello\")\n}\n",
+		VersionCmd: []string{"/usr/local/bin/kotlinc", "-version"},
+	},
+	CSHARP: {
+		Source:     "using System;\n\nclass Program {\n    static void Main() {\n        Console.WriteLine(\"hello\");\n    }\n}\n",
+		VersionCmd: []string{"/usr/bin/mcs", "--version"},
+	},
+	TYPESCRIPT: {
+		Source:     "const greeting: string = \"hello\";\nconsole.log(greeting);\n",
+		VersionCmd: []string{"/usr/local/bin/tsc", "--version"},
+	},
+}
+
+type selfTestResult struct {
+	OK        bool
+	Version   string
+	LatencyMs int64
+	Error     string
+}
+
+// languageHealth 는 self-test 결과와 readiness 검사로 정해진 사용 불가 언어를 담는다.
+var languageHealth struct {
+	mu       sync.Mutex
+	selfTest map[string]selfTestResult
+	disabled map[string]string
+}
+
+func languageSelfTest(name string) (selfTestResult, bool) {
+	languageHealth.mu.Lock()
+	defer languageHealth.mu.Unlock()
+	result, ok := languageHealth.selfTest[name]
+	return result, ok
+}
+
+// languageDisabled 는 언어를 쓸 수 없으면 그 이유를 돌려준다.
+func languageDisabled(name string) (string, bool) {
+	languageHealth.mu.Lock()
+	defer languageHealth.mu.Unlock()
+	reason, ok := languageHealth.disabled[name]
+	return reason, ok
+}
+
+func setDisabledLanguages(disabled map[string]string) {
+	languageHealth.mu.Lock()
+	defer languageHealth.mu.Unlock()
+	languageHealth.disabled = disabled
+}
+
+// runSelfTests 는 readiness 검사 box 를 빌려서 언어를 하나씩 시험한다. 세션 box 는 쓰지 않는다.
+func runSelfTests() {
+	box := &Box{ID: readinessBoxID, Workspace: filepath.Join(boxWorkspaceRoot, strconv.Itoa(readinessBoxID))}
+	results := map[string]selfTestResult{}
+
+	names := make([]string, 0, len(selfTestPrograms))
+	for name := range selfTestPrograms {
+		if _, ok := CompileOptions[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		program := selfTestPrograms[name]
+		result := selfTestResult{Version: toolchainVersion(program.VersionCmd)}
+		start := time.Now()
+		err := runSelfTest(box, name, program)
+		result.LatencyMs = elapsedMs(start)
+		result.OK = err == nil
+		if err != nil {
+			result.Error = err.Error()
+			log.Printf("self-test %s failed after %dms: %v", name, result.LatencyMs, err)
+		} else {
+			log.Printf("self-test %s ok in %dms (%s)", name, result.LatencyMs, result.Version)
+		}
+		results[name] = result
+	}
+
+	languageHealth.mu.Lock()
+	languageHealth.selfTest = results
+	languageHealth.mu.Unlock()
+}
+
+func runSelfTest(box *Box, language string, program selfTestProgram) error {
+	_ = box.cleanup()
+	if err := box.init(); err != nil {
+		return fmt.Errorf("failed to init isolate: %w", err)
+	}
+	defer func() {
+		if err := box.cleanup(); err != nil {
+			log.Println("self-test cleanup error:", err)
+		}
+	}()
+
+	var mu sync.Mutex
+	var stdout, stderr strings.Builder
+	exited := make(chan map[string]interface{}, 1)
+	ctx := &ConnectionContext{box: box}
+	ctx.sink = func(v interface{}) {
+		event, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch event["type"] {
+		case "stdout":
+			stdout.WriteString(fmt.Sprint(event["data"]))
+		case "stderr":
+			stderr.WriteString(fmt.Sprint(event["data"]))
+		case "compile_error":
+			stderr.WriteString(fmt.Sprint(event["stderr"]))
+		case "error":
+			stderr.WriteString(fmt.Sprint(event["error"]))
+		case "exit":
+			exited <- event
+		}
+	}
+
+	msg := &Message{Type: "code", Language: language, Source: program.Source, Mode: ModeRun}
+	if err := handleCode(ctx, msg); err != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var exit map[string]interface{}
+	select {
+	case exit = <-exited:
+	case <-time.After(selfTestTimeout):
+		ctx.stopProcess()
+		ctx.artifacts.Wait()
+		return fmt.Errorf("timed out after %s", selfTestTimeout)
+	}
+	ctx.artifacts.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if code, _ := exit["return_code"].(int); code != 0 {
+		return fmt.Errorf("exit code %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	if !program.ExitOnly && strings.TrimSpace(stdout.String()) != selfTestExpect {
+		return fmt.Errorf("unexpected output %q", stdout.String())
+	}
+	return nil
+}
+
+// toolchainVersion 은 버전 명령 출력의 첫 줄을 돌려준다. 실패하면 빈 문자열이다.
+func toolchainVersion(cmd []string) string {
+	if len(cmd) == 0 {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelfTestProgramsAreKnownLanguages(t *testing.T) {
+	for name, program := range selfTestPrograms {
+		if _, ok := CompileOptions[name]; !ok {
+			t.Errorf("self-test for unknown language %s", name)
+		}
+		if strings.TrimSpace(program.Source) == "" {
+			t.Errorf("%s has an empty self-test program", name)
+		}
+	}
+}
+
+func TestToolchainVersion(t *testing.T) {
+	if got := toolchainVersion([]string{"/bin/sh", "-c", "echo; echo '  tool 1.2  '; echo extra"}); got != "tool 1.2" {
+		t.Errorf("toolchainVersion() = %q", got)
+	}
+	if got := toolchainVersion([]string{"/bin/sh", "-c", "echo broken; exit 1"}); got != "" {
+		t.Errorf("toolchainVersion(failing) = %q", got)
+	}
+	if got := toolchainVersion(nil); got != "" {
+		t.Errorf("toolchainVersion(nil) = %q", got)
+	}
+}
+
+func TestFailedSelfTestDisablesLanguage(t *testing.T) {
+	languageHealth.mu.Lock()
+	selfTest, disabled := languageHealth.selfTest, languageHealth.disabled
+	languageHealth.selfTest = map[string]selfTestResult{
+		PYTHON: {OK: false, Version: "Python 3.11.2", Error: "exit code 1"},
+	}
+	languageHealth.mu.Unlock()
+	defer func() {
+		languageHealth.mu.Lock()
+		languageHealth.selfTest, languageHealth.disabled = selfTest, disabled
+		languageHealth.mu.Unlock()
+	}()
+
+	check := checkLanguage(PYTHON)
+	if check.OK || !check.Disabled || check.Version != "Python 3.11.2" {
+		t.Fatalf("check = %+v", check)
+	}
+	// 실행 파일이 없는 환경에서는 그 이유가 먼저 나온다.
+	if !strings.Contains(check.Detail, "self-test failed: exit code 1") && !strings.Contains(check.Detail, "missing or not executable") {
+		t.Errorf("detail = %q", check.Detail)
+	}
+
+	setDisabledLanguages(map[string]string{PYTHON: check.Detail})
+	for _, capability := range languageCapabilities() {
+		if capability.Name != PYTHON {
+			continue
+		}
+		if !capability.Disabled || capability.DisabledReason != check.Detail || capability.Version != "Python 3.11.2" {
+			t.Errorf("capability = %+v", capability)
+		}
+	}
+}
+
+// self-test 는 연결 없이 handleCode 를 돌리므로 실행 상태를 바꿔도 연결을 건드리면 안 된다.
+func TestRunStateWithoutConnection(t *testing.T) {
+	ctx := &ConnectionContext{sink: func(interface{}) {}}
+	ctx.startRun()
+	ctx.finishRun()
+	ctx.touch()
+	if !ctx.exited {
+		t.Error("finishRun did not mark the run as exited")
+	}
+}
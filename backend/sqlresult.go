@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// SQL 은 tools/sqlrun.py 가 isolate 안에서 sqlite3 로 실행한다. 결과 집합은 stdout 이 아니라
+// sqlResultsFile 에 JSON 으로 남기고, 러너가 실행이 끝난 뒤 sql_result 이벤트로 보낸다.
+// 출제자 스키마/시드와 judge 테스트 케이스 데이터는 사용자 스크립트보다 먼저 실행된다.
+const (
+	sqlDatabaseFile = "/code/.database.sqlite"
+	sqlResultsFile  = "/code/.sql-results.json"
+	sqlSchemaFile   = "/code/.schema.sql"
+	sqlSeedFile     = "/code/.seed.sql"
+	sqlCaseFile     = ".case.sql"
+	sqlResultsLimit = 16 * 1024 * 1024
+)
+
+// SQLDatabase 는 실행 전에 데이터베이스에 적용할 출제자 스크립트다.
+type SQLDatabase struct {
+	Schema string `json:"schema"`
+	Seed   string `json:"seed"`
+}
+
+type SQLStatementResult struct {
+	SQL          string          `json:"sql"`
+	Columns      []string        `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowsAffected int64           `json:"rows_affected"`
+	Truncated    bool            `json:"truncated"`
+	Error        *string         `json:"error"`
+}
+
+// SQLResultSet 은 judge 에서 기대하는 결과 집합이다. 열 이름은 비교하지 않는다.
+type SQLResultSet struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+func writeSQLDatabase(box *Box, db *SQLDatabase) error {
+	if db == nil {
+		return nil
+	}
+	for path, content := range map[string]string{sqlSchemaFile: db.Schema, sqlSeedFile: db.Seed} {
+		if content == "" {
+			continue
+		}
+		if err := box.writeFile(box.path(path), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSQLResults(box *Box) ([]SQLStatementResult, error) {
+	// 사용자 프로그램이 쓴 파일이므로 symlink 를 따라가지 않는다.
+	data, err := box.readFile(box.path(sqlResultsFile), sqlResultsLimit)
+	if err != nil {
+		return nil, err
+	}
+	var results struct {
+		Statements []SQLStatementResult `json:"statements"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("invalid sql results: %w", err)
+	}
+	return results.Statements, nil
+}
+
+func sendSQLResults(ctx *ConnectionContext) {
+	statements, err := readSQLResults(ctx.box)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "error",
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.write(map[string]interface{}{
+		"type":       "sql_result",
+		"statements": statements,
+	})
+}
+
+// lastResultSet 은 스크립트에서 마지막으로 결과 집합을 돌려준 문장을 찾는다.
+func lastResultSet(statements []SQLStatementResult) *SQLStatementResult {
+	for i := len(statements) - 1; i >= 0; i-- {
+		if statements[i].Error == nil && statements[i].Columns != nil {
+			return &statements[i]
+		}
+	}
+	return nil
+}
+
+// resultSetsMatch 는 열 개수와 행 값을 비교한다. ordered 가 아니면 행 순서를 무시한다.
+// 값은 JSON 으로 읽은 뒤라 정수와 실수는 같은 숫자면 같다.
+func resultSetsMatch(actual *SQLStatementResult, expected *SQLResultSet, ordered bool) bool {
+	if len(actual.Columns) != len(expected.Columns) && len(expected.Columns) > 0 {
+		return false
+	}
+	if len(actual.Rows) != len(expected.Rows) {
+		return false
+	}
+
+	actualKeys := make([]string, len(actual.Rows))
+	expectedKeys := make([]string, len(expected.Rows))
+	for i := range actual.Rows {
+		actualKeys[i] = sqlRowKey(actual.Rows[i])
+		expectedKeys[i] = sqlRowKey(expected.Rows[i])
+	}
+	if !ordered {
+		sort.Strings(actualKeys)
+		sort.Strings(expectedKeys)
+	}
+	for i := range actualKeys {
+		if actualKeys[i] != expectedKeys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sqlRowKey(row []interface{}) string {
+	values := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			values[i] = "null"
+		case float64:
+			values[i] = "n:" + strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			values[i] = "s:" + v
+		default:
+			values[i] = fmt.Sprintf("%T:%v", v, v)
+		}
+	}
+	key, _ := json.Marshal(values)
+	return string(key)
+}
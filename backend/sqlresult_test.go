@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLRowKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		// JSON 으로 읽으면 정수와 실수는 같은 숫자다.
+		{`[1, "a"]`, `[1.0, "a"]`, true},
+		{`[null]`, `[null]`, true},
+		{`[1]`, `["1"]`, false},
+		{`[null]`, `["null"]`, false},
+		{`["a,b"]`, `["a", "b"]`, false},
+		{`[true]`, `[1]`, false},
+	}
+	for _, tt := range tests {
+		var a, b []interface{}
+		if err := json.Unmarshal([]byte(tt.a), &a); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.b), &b); err != nil {
+			t.Fatal(err)
+		}
+		if got := sqlRowKey(a) == sqlRowKey(b); got != tt.same {
+			t.Errorf("sqlRowKey(%s) == sqlRowKey(%s) = %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestResultSetsMatch(t *testing.T) {
+	var expected SQLResultSet
+	if err := json.Unmarshal([]byte(`{"columns": ["id", "name"], "rows": [[1, "a"], [2, null]]}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	parse := func(s string) *SQLStatementResult {
+		var result SQLStatementResult
+		if err := json.Unmarshal([]byte(s), &result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+
+	tests := []struct {
+		name      string
+		actual    string
+		ordered   bool
+		unordered bool
+	}{
+		{"same", `{"columns": ["id", "name"], "rows": [[1, "a"], [2, null]]}`, true, true},
+		{"other column names", `{"columns": ["x", "y"], "rows": [[1.0, "a"], [2, null]]}`, true, true},
+		{"reordered rows", `{"columns": ["id", "name"], "rows": [[2, null], [1, "a"]]}`, false, true},
+		{"missing row", `{"columns": ["id", "name"], "rows": [[1, "a"]]}`, false, false},
+		{"extra column", `{"columns": ["id", "name", "x"], "rows": [[1, "a", 0], [2, null, 0]]}`, false, false},
+		{"different value", `{"columns": ["id", "name"], "rows": [[1, "a"], [2, ""]]}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := parse(tt.actual)
+			if got := resultSetsMatch(actual, &expected, true); got != tt.ordered {
+				t.Errorf("ordered = %v, want %v", got, tt.ordered)
+			}
+			if got := resultSetsMatch(actual, &expected, false); got != tt.unordered {
+				t.Errorf("unordered = %v, want %v", got, tt.unordered)
+			}
+		})
+	}
+
+	// 기대값에 열이 없으면 열 개수는 보지 않는다.
+	if !resultSetsMatch(parse(`{"columns": ["a"], "rows": [[1]]}`), &SQLResultSet{Rows: [][]interface{}{{1.0}}}, true) {
+		t.Error("expected set without columns did not match")
+	}
+}
+
+func TestLastResultSet(t *testing.T) {
+	message := "no such table"
+	statements := []SQLStatementResult{
+		{SQL: "SELECT 1", Columns: []string{"1"}},
+		{SQL: "INSERT INTO t VALUES (1)"},
+		{SQL: "SELECT * FROM missing", Error: &message},
+	}
+	if got := lastResultSet(statements); got == nil || got.SQL != "SELECT 1" {
+		t.Fatalf("lastResultSet = %v", got)
+	}
+	if got := lastResultSet(statements[1:]); got != nil {
+		t.Fatalf("lastResultSet = %v, want nil", got)
+	}
+}
+
+func TestSQLRunner(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.sql": "CREATE TABLE t (id INTEGER, name TEXT);",
+		"seed.sql":   "INSERT INTO t VALUES (1, 'a;b');",
+		"main.sql":   "INSERT INTO t VALUES (2, NULL);\nSELECT * FROM missing;\nSELECT id, name FROM t ORDER BY id;\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := filepath.Join(dir, "results.json")
+	cmd := exec.Command(python, filepath.Join("tools", "sqlrun.py"),
+		"--database", filepath.Join(dir, "db.sqlite"), "--results", results,
+		"--setup", filepath.Join(dir, "schema.sql"), "--setup", filepath.Join(dir, "seed.sql"),
+		"--setup", filepath.Join(dir, "missing.sql"), filepath.Join(dir, "main.sql"))
+	cmd.Env = append(os.Environ(), "PYTHONDONTWRITEBYTECODE=1")
+	// 실패한 문장이 있으면 종료 코드가 1 이지만 나머지 문장은 실행한다.
+	if err := cmd.Run(); cmd.ProcessState == nil || cmd.ProcessState.ExitCode() != 1 {
+		t.Fatalf("exit = %v", err)
+	}
+
+	data, err := os.ReadFile(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Statements []SQLStatementResult `json:"statements"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Statements) != 3 || parsed.Statements[0].RowsAffected != 1 || parsed.Statements[1].Error == nil {
+		t.Fatalf("statements = %+v", parsed.Statements)
+	}
+	expected := &SQLResultSet{Rows: [][]interface{}{{1.0, "a;b"}, {2.0, nil}}}
+	if last := lastResultSet(parsed.Statements); last == nil || !resultSetsMatch(last, expected, true) {
+		t.Fatalf("last result set = %+v", last)
+	}
+}
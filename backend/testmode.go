@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	testOutputLimit  = 1024 * 1024
+	testMessageLimit = 4 * 1024
+)
+
+const (
+	TestPassed  = "passed"
+	TestFailed  = "failed"
+	TestSkipped = "skipped"
+	TestError   = "error"
+)
+
+type TestCaseResult struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Message  string  `json:"message,omitempty"`
+	Duration float64 `json:"duration"`
+}
+
+// redactTestDiagnostics 는 출제자 테스트 파일을 가리키는 진단을 빼고 남은 진단만으로 출력을 다시 만든다.
+// 컴파일러의 원시 출력은 테스트 파일의 소스 줄을 인용하므로 그대로 보내지 않는다.
+func redactTestDiagnostics(output string, hidden map[string]bool) (string, []Diagnostic) {
+	visible := []Diagnostic{}
+	var lines []string
+	redacted := 0
+	for _, d := range parseDiagnostics(output) {
+		if hidden[d.File] {
+			redacted++
+			continue
+		}
+		visible = append(visible, d)
+		location := fmt.Sprintf("%s:%d", d.File, d.Line)
+		if d.Column > 0 {
+			location += fmt.Sprintf(":%d", d.Column)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message))
+	}
+	if redacted > 0 {
+		lines = append(lines, fmt.Sprintf("%d problem(s) in test files (details hidden)", redacted))
+	}
+	return strings.Join(lines, "\n"), visible
+}
+
+// 테스트 파일 내용이 새어 나가지 않도록 원시 출력은 보내지 않고 결과만 보낸다.
+func runTests(ctx *ConnectionContext, option CompileOption, testFiles []WorkspaceFile) error {
+	test := option.Test
+	if len(test.CompileCmd) > 0 {
+		sources, err := workspaceSources(ctx.box, test.SourceExt)
+		if err != nil {
+			ctx.write(map[string]interface{}{
+				"type":  "error",
+				"error": fmt.Sprintf("failed to list sources: %v", err),
+			})
+			return err
+		}
+
+		compileCmd := append(append([]string{}, test.CompileCmd...), sources...)
+		hidden := map[string]bool{}
+		for _, file := range testFiles {
+			hidden[filepath.Clean(file.Name)] = true
+		}
+		output, compileErr := ctx.box.runCommand(compileCmd)
+		output, diagnostics := redactTestDiagnostics(output, hidden)
+		if compileErr != nil {
+			if output == "" {
+				output = "compilation failed"
+			}
+			ctx.write(map[string]interface{}{
+				"type":        "compile_error",
+				"stderr":      output,
+				"diagnostics": diagnostics,
+			})
+			return compileErr
+		}
+
+		ctx.write(map[string]interface{}{
+			"type":        "compile_success",
+			"stdout":      output,
+			"diagnostics": diagnostics,
+		})
+	}
+
+	args := ctx.box.isolateArgs()
+	args = append(args,
+		"--processes",
+		"--time=20",
+		"--wall-time=40",
+		// JUnit console launcher 는 /opt 에 있다.
+		"--dir=/opt",
+		"--env=HOME=/box",
+		"--env=PYTHONDONTWRITEBYTECODE=1",
+		"--silent",
+		"--run", "--",
+	)
+	args = append(args, test.ExecuteCmd...)
+
+	stdout := &limitedBuffer{limit: testOutputLimit}
+	cmd := exec.Command(isolateBinary, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = io.Discard
+
+	start := time.Now()
+	runErr := cmd.Run()
+	elapsed := time.Since(start)
+
+	var results []TestCaseResult
+	var parseErr error
+	switch test.Report {
+	case TestReportGoJSON:
+		results, parseErr = parseGoTestJSON(stdout.Bytes())
+	case TestReportJUnit:
+		results, parseErr = parseJUnitReport(ctx.box, ctx.box.path(test.ReportPath))
+	default:
+		parseErr = fmt.Errorf("unknown report format: %s", test.Report)
+	}
+
+	if parseErr != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "test_error",
+			"error": fmt.Sprintf("failed to collect test results: %v (runner: %v)", parseErr, runErr),
+		})
+	} else {
+		summary := map[string]int{"total": len(results)}
+		for _, r := range results {
+			summary[r.Status]++
+		}
+		ctx.write(map[string]interface{}{
+			"type":     "test_result",
+			"tests":    results,
+			"summary":  summary,
+			"duration": elapsed.Seconds(),
+		})
+	}
+
+	if option.coverage {
+		exclude := map[string]bool{}
+		for _, file := range testFiles {
+			if path, err := workspacePath(file.Name); err == nil {
+				exclude[path] = true
+			}
+		}
+		sendCoverage(ctx, option.Coverage, exclude)
+	}
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	ctx.write(map[string]interface{}{
+		"type":        "exit",
+		"return_code": exitCode,
+		"error":       fmt.Sprintf("%v", runErr),
+	})
+	ctx.finishRun()
+	return nil
+}
+
+func workspaceSources(box *Box, ext string) ([]string, error) {
+	var sources []string
+	err := filepath.WalkDir(box.Workspace, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ext) {
+			rel, _ := filepath.Rel(box.Workspace, path)
+			sources = append(sources, filepath.Join(workspaceDir, rel))
+		}
+		return nil
+	})
+	sort.Strings(sources)
+	return sources, err
+}
+
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+func parseGoTestJSON(data []byte) ([]TestCaseResult, error) {
+	outputs := map[string]*strings.Builder{}
+	results := []TestCaseResult{}
+	sawEvent := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), testOutputLimit)
+	for scanner.Scan() {
+		var event goTestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		sawEvent = true
+		if event.Test == "" {
+			continue
+		}
+
+		switch event.Action {
+		case "output":
+			b, ok := outputs[event.Test]
+			if !ok {
+				b = &strings.Builder{}
+				outputs[event.Test] = b
+			}
+			if b.Len() < testMessageLimit && !strings.HasPrefix(strings.TrimSpace(event.Output), "=== ") &&
+				!strings.HasPrefix(strings.TrimSpace(event.Output), "--- ") {
+				b.WriteString(event.Output)
+			}
+		case "pass", "fail", "skip":
+			status := map[string]string{"pass": TestPassed, "fail": TestFailed, "skip": TestSkipped}[event.Action]
+			message := ""
+			if b, ok := outputs[event.Test]; ok && status != TestPassed {
+				message = truncateMessage(b.String())
+			}
+			results = append(results, TestCaseResult{
+				Name:     event.Test,
+				Status:   status,
+				Message:  message,
+				Duration: event.Elapsed,
+			})
+		}
+	}
+
+	if !sawEvent {
+		return nil, fmt.Errorf("no test events in output")
+	}
+	return results, nil
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// testsuites/testsuite 중첩 구조가 도구마다 달라서 testcase 요소만 골라서 읽는다.
+// 리포트는 샌드박스가 쓴 파일이라 symlink 를 따라가지 않고 크기를 제한해서 읽는다.
+func parseJUnitReport(box *Box, path string) ([]TestCaseResult, error) {
+	data, err := box.readFile(path, testOutputLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []TestCaseResult{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "testcase" {
+			continue
+		}
+
+		var tc junitTestCase
+		if err := decoder.DecodeElement(&tc, &start); err != nil {
+			return nil, err
+		}
+
+		name := tc.Name
+		if tc.ClassName != "" {
+			name = tc.ClassName + "." + tc.Name
+		}
+		duration, _ := strconv.ParseFloat(tc.Time, 64)
+
+		result := TestCaseResult{Name: name, Status: TestPassed, Duration: duration}
+		switch {
+		case tc.Failure != nil:
+			result.Status = TestFailed
+			result.Message = truncateMessage(junitText(tc.Failure))
+		case tc.Error != nil:
+			result.Status = TestError
+			result.Message = truncateMessage(junitText(tc.Error))
+		case tc.Skipped != nil:
+			result.Status = TestSkipped
+			result.Message = truncateMessage(junitText(tc.Skipped))
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func junitText(m *junitMessage) string {
+	if m.Message != "" {
+		return m.Message
+	}
+	return strings.TrimSpace(m.Text)
+}
+
+func truncateMessage(message string) string {
+	if len(message) > testMessageLimit {
+		return message[:testMessageLimit] + "..."
+	}
+	return message
+}
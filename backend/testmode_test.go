@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactTestDiagnostics(t *testing.T) {
+	output := "/code/test_main.c: In function 'test_secret':\n" +
+		"/code/test_main.c:12:5: error: expected 42 == answer(SECRET_INPUT)\n" +
+		"   12 |     assert(answer(\"hidden\") == 42);\n" +
+		"/code/main.c:3:1: warning: control reaches end of non-void function\n"
+	hidden := map[string]bool{"test_main.c": true}
+
+	got, diagnostics := redactTestDiagnostics(output, hidden)
+	want := "main.c:3:1: warning: control reaches end of non-void function\n1 problem(s) in test files (details hidden)"
+	if got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].File != "main.c" {
+		t.Errorf("diagnostics = %+v, want only main.c", diagnostics)
+	}
+	for _, secret := range []string{"SECRET_INPUT", "hidden\"", "test_secret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("output leaks %q: %q", secret, got)
+		}
+	}
+}
+
+func TestRedactTestDiagnosticsGoAndJava(t *testing.T) {
+	goOutput := "# command-line-arguments\n./main_test.go:7:2: undefined: secretCase\n./main.go:3:1: missing return"
+	got, _ := redactTestDiagnostics(goOutput, map[string]bool{"main_test.go": true})
+	if strings.Contains(got, "secretCase") || !strings.Contains(got, "main.go:3:1: error: missing return") {
+		t.Errorf("go output = %q", got)
+	}
+
+	javaOutput := "/code/MainTest.java:5: error: cannot find symbol\n        assertEquals(SECRET, Main.run());\n                     ^\n1 error"
+	got, diagnostics := redactTestDiagnostics(javaOutput, map[string]bool{"MainTest.java": true})
+	if strings.Contains(got, "SECRET") || len(diagnostics) != 0 {
+		t.Errorf("java output leaks test source: %q %+v", got, diagnostics)
+	}
+
+	got, _ = redactTestDiagnostics("/code/main.c:1:1: error: boom", map[string]bool{"test_main.c": true})
+	if got != "main.c:1:1: error: boom" {
+		t.Errorf("output without test diagnostics = %q", got)
+	}
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	data := []byte(`{"Action":"run","Test":"TestAdd"}
+{"Action":"output","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"pass","Test":"TestAdd","Elapsed":0.01}
+{"Action":"output","Test":"TestSub","Output":"    main_test.go:9: got 1, want 2\n"}
+{"Action":"fail","Test":"TestSub","Elapsed":0.02}
+{"Action":"skip","Test":"TestMul"}
+`)
+	results, err := parseGoTestJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].Status != TestPassed || results[0].Message != "" {
+		t.Errorf("TestAdd = %+v", results[0])
+	}
+	if results[1].Status != TestFailed || !strings.Contains(results[1].Message, "got 1, want 2") {
+		t.Errorf("TestSub = %+v", results[1])
+	}
+	if results[2].Status != TestSkipped {
+		t.Errorf("TestMul = %+v", results[2])
+	}
+
+	if _, err := parseGoTestJSON([]byte("not json\n")); err == nil {
+		t.Error("output without events was accepted")
+	}
+}
+
+// isolate 가 설치된 러너 이미지에서만 돈다. 샌드박스 사용자가 workspace 에 파일을 쓸 수 있어야 한다.
+func TestSandboxWritesWorkspace(t *testing.T) {
+	if _, err := os.Stat(isolateBinary); err != nil {
+		t.Skip("isolate is not installed")
+	}
+	box, err := boxes.acquire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer boxes.release(box)
+	if err := writeWorkspaceFiles(box, []WorkspaceFile{{Name: "reports/keep.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	args := append(box.isolateArgs(), "--processes", "--run", "--",
+		"/bin/sh", "-c", "echo ok > /code/out.txt && echo ok > /code/reports/out.txt")
+	if output, err := exec.Command(isolateBinary, args...).CombinedOutput(); err != nil {
+		t.Fatalf("sandboxed write failed: %v: %s", err, output)
+	}
+	for _, name := range []string{"out.txt", "reports/out.txt"} {
+		data, err := box.readFile(filepath.Join(box.Workspace, name), 16)
+		if err != nil || string(data) != "ok\n" {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+import java.io.BufferedReader;
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.io.InputStreamReader;
+import java.io.OutputStream;
+import java.io.PrintStream;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.List;
+import javax.tools.JavaCompiler;
+import javax.tools.ToolProvider;
+
+/**
+ * 러너가 띄워 두는 javac 데몬. stdin 으로 한 줄에 한 번씩 탭으로 구분한 javac 인자를 받아
+ * 같은 JVM 에서 컴파일하고, "<exit code> <출력 바이트 수>\n" 다음에 javac 출력을 돌려준다.
+ * 출력은 javac 명령과 같은 형식이라 러너의 진단 파서를 그대로 쓴다.
+ * 사용자 코드가 이 JVM 에서 실행되지 않도록 annotation processor 는 항상 끈다.
+ */
+public class JavacDaemon {
+    public static void main(String[] args) throws IOException {
+        JavaCompiler compiler = ToolProvider.getSystemJavaCompiler();
+        OutputStream out = System.out;
+        // 컴파일러가 표준 출력에 쓰더라도 응답 스트림이 깨지지 않게 한다.
+        System.setOut(System.err);
+
+        BufferedReader in = new BufferedReader(new InputStreamReader(System.in, StandardCharsets.UTF_8));
+        String line;
+        while ((line = in.readLine()) != null) {
+            List<String> argv = new ArrayList<>();
+            argv.add("-proc:none");
+            if (!line.isEmpty()) {
+                argv.addAll(Arrays.asList(line.split("\t")));
+            }
+
+            ByteArrayOutputStream output = new ByteArrayOutputStream();
+            int code;
+            try {
+                code = compiler.run(null, output, output, argv.toArray(new String[0]));
+            } catch (Throwable t) {
+                t.printStackTrace(new PrintStream(output, true));
+                code = 4;
+            }
+
+            byte[] bytes = output.toByteArray();
+            out.write((code + " " + bytes.length + "\n").getBytes(StandardCharsets.UTF_8));
+            out.write(bytes);
+            out.flush();
+        }
+    }
+}
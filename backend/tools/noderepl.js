@@ -0,0 +1,134 @@
+// Persistent REPL driver for the repl mode.
+//
+// Usage: noderepl.js
+//
+// Every line on stdin is a JSON request {"id": N, "code": "..."}. Cells run in one
+// vm context that lives as long as this process, so top-level declarations carry
+// over between cells. For each request exactly one JSON line is written to stdout:
+//
+//   {"id": N, "stdout": "...", "stderr": "...", "value": "..." | null,
+//    "error": "..." | null, "traceback": "..." | null}
+//
+// A trailing promise is awaited before the result is reported.
+
+"use strict";
+
+const readline = require("readline");
+const util = require("util");
+const vm = require("vm");
+
+const OUTPUT_LIMIT = 64 * 1024;
+
+class Capture {
+  constructor() {
+    this.text = "";
+    this.truncated = false;
+  }
+
+  write(s) {
+    if (this.text.length >= OUTPUT_LIMIT) {
+      this.truncated = true;
+      return;
+    }
+    const chunk = s.slice(0, OUTPUT_LIMIT - this.text.length);
+    if (chunk.length < s.length) {
+      this.truncated = true;
+    }
+    this.text += chunk;
+  }
+
+  value() {
+    return this.truncated ? this.text + "\n[output truncated]\n" : this.text;
+  }
+}
+
+let stdout = new Capture();
+let stderr = new Capture();
+
+// console methods write into the capture of the cell that is currently running.
+// Output from timers that fire after a cell finishes goes to the next cell.
+const format = (args) => util.formatWithOptions({ colors: false }, ...args) + "\n";
+const cellConsole = {
+  log: (...args) => stdout.write(format(args)),
+  info: (...args) => stdout.write(format(args)),
+  debug: (...args) => stdout.write(format(args)),
+  dir: (value) => stdout.write(util.inspect(value) + "\n"),
+  table: (...args) => stdout.write(format(args)),
+  error: (...args) => stderr.write(format(args)),
+  warn: (...args) => stderr.write(format(args)),
+  trace: (...args) => stderr.write(format(args)),
+};
+
+const context = vm.createContext({
+  console: cellConsole,
+  require,
+  Buffer,
+  URL,
+  TextEncoder,
+  TextDecoder,
+  setTimeout,
+  clearTimeout,
+  setInterval,
+  clearInterval,
+  setImmediate,
+  clearImmediate,
+  queueMicrotask,
+});
+
+// errors thrown inside the context come from another realm, so instanceof Error does not work
+const isError = (err) => err !== null && typeof err === "object" && typeof err.stack === "string";
+
+function cellTraceback(err) {
+  if (!isError(err)) {
+    return String(err);
+  }
+  // drop our own frames so the traceback ends at the cell
+  return err.stack
+    .split("\n")
+    .filter((line) => !line.includes(__filename) && !line.includes("node:vm"))
+    .join("\n");
+}
+
+async function runCell(id, code) {
+  const result = { id, value: null, error: null, traceback: null };
+  try {
+    let value = vm.runInContext(code, context, { filename: `<cell-${id}>` });
+    if (value && typeof value.then === "function") {
+      value = await value;
+    }
+    if (value !== undefined) {
+      let text = util.inspect(value, { depth: 4 });
+      if (text.length > OUTPUT_LIMIT) {
+        text = text.slice(0, OUTPUT_LIMIT) + "...";
+      }
+      result.value = text;
+    }
+  } catch (err) {
+    result.error = isError(err) ? `${err.name}: ${err.message}` : `Uncaught ${util.inspect(err)}`;
+    result.traceback = cellTraceback(err);
+  }
+
+  result.stdout = stdout.value();
+  result.stderr = stderr.value();
+  stdout = new Capture();
+  stderr = new Capture();
+  process.stdout.write(JSON.stringify(result) + "\n");
+}
+
+// errors thrown from callbacks after a cell finished are reported with the next cell
+process.on("uncaughtException", (err) => stderr.write(cellTraceback(err) + "\n"));
+process.on("unhandledRejection", (err) => stderr.write(cellTraceback(err) + "\n"));
+
+let queue = Promise.resolve();
+readline.createInterface({ input: process.stdin }).on("line", (line) => {
+  let request;
+  try {
+    request = JSON.parse(line);
+  } catch (err) {
+    return;
+  }
+  if (typeof request.id !== "number" || typeof request.code !== "string") {
+    return;
+  }
+  queue = queue.then(() => runCell(request.id, request.code));
+});
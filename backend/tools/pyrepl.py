@@ -0,0 +1,110 @@
+"""Persistent REPL driver for the repl mode.
+
+Usage: pyrepl.py
+
+Every line on stdin is a JSON request {"id": N, "code": "..."}. Cells run in one
+namespace that lives as long as this process. For each request exactly one JSON
+line is written to the real stdout:
+
+    {"id": N, "stdout": "...", "stderr": "...", "value": "..." | null,
+     "error": "..." | null, "traceback": "..." | null}
+
+The value of a trailing expression is reported like the interactive interpreter
+does. The cell's own stdin is empty because ours carries the requests.
+"""
+
+import ast
+import builtins
+import io
+import json
+import linecache
+import sys
+import traceback
+
+OUTPUT_LIMIT = 64 * 1024
+
+
+class Capture(io.StringIO):
+    def __init__(self):
+        super().__init__()
+        self.size = 0
+        self.truncated = False
+
+    def write(self, s):
+        if self.size >= OUTPUT_LIMIT:
+            self.truncated = True
+            return len(s)
+        chunk = s[: OUTPUT_LIMIT - self.size]
+        self.size += len(chunk)
+        if len(chunk) < len(s):
+            self.truncated = True
+        return super().write(chunk)
+
+    def text(self):
+        value = self.getvalue()
+        if self.truncated:
+            value += "\n[output truncated]\n"
+        return value
+
+
+def cell_traceback(exc):
+    # drop our own frames so the traceback starts at the cell
+    tb = exc.__traceback__
+    while tb is not None and tb.tb_frame.f_code.co_filename == __file__:
+        tb = tb.tb_next
+    return "".join(traceback.format_exception(type(exc), exc, tb))
+
+
+def run_cell(cell_id, code, namespace):
+    filename = "<cell-%d>" % cell_id
+    linecache.cache[filename] = (len(code), None, code.splitlines(True), filename)
+
+    tree = ast.parse(code, filename, "exec")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+
+    exec(compile(tree, filename, "exec"), namespace)
+    if last is None:
+        return None
+    value = eval(compile(last, filename, "eval"), namespace)
+    if value is None:
+        return None
+    builtins._ = value
+    return repr(value)
+
+
+def main():
+    requests = sys.stdin
+    results = sys.stdout
+    namespace = {"__name__": "__main__", "__builtins__": builtins}
+
+    for line in requests:
+        try:
+            request = json.loads(line)
+            cell_id = int(request["id"])
+            code = str(request["code"])
+        except (ValueError, KeyError, TypeError):
+            continue
+
+        stdout, stderr = Capture(), Capture()
+        sys.stdin, sys.stdout, sys.stderr = io.StringIO(), stdout, stderr
+        result = {"id": cell_id, "value": None, "error": None, "traceback": None}
+        try:
+            result["value"] = run_cell(cell_id, code, namespace)
+        except BaseException as exc:  # noqa: B036 - SystemExit must not end the session
+            result["error"] = "%s: %s" % (type(exc).__name__, exc)
+            result["traceback"] = cell_traceback(exc)
+        finally:
+            sys.stdin, sys.stdout, sys.stderr = sys.__stdin__, results, sys.__stderr__
+
+        result["stdout"] = stdout.text()
+        result["stderr"] = stderr.text()
+        if result["value"] is not None and len(result["value"]) > OUTPUT_LIMIT:
+            result["value"] = result["value"][:OUTPUT_LIMIT] + "..."
+        results.write(json.dumps(result) + "\n")
+        results.flush()
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,35 @@
+"""Headless matplotlib backend for the runner.
+
+Selected with MPLBACKEND=module://iris_mpl. Figures are rendered with Agg and
+plt.show() saves every open figure as a PNG into $IRIS_IMAGE_DIR, where the
+runner picks them up and sends them to the client as image events.
+"""
+
+import itertools
+import os
+
+from matplotlib._pylab_helpers import Gcf
+from matplotlib.backend_bases import FigureManagerBase
+from matplotlib.backends.backend_agg import FigureCanvasAgg
+
+FigureCanvas = FigureCanvasAgg
+FigureManager = FigureManagerBase
+
+_counter = itertools.count(1)
+
+
+def _save(figure):
+    directory = os.environ.get("IRIS_IMAGE_DIR", ".")
+    name = "figure-%d-%d.png" % (os.getpid(), next(_counter))
+    path = os.path.join(directory, name)
+    # the runner only looks at image extensions, so write under a temporary
+    # name first and rename once the file is complete
+    partial = os.path.join(directory, "." + name + ".part")
+    figure.savefig(partial, format="png")
+    os.replace(partial, path)
+
+
+def show(*args, **kwargs):
+    for manager in Gcf.get_all_fig_managers():
+        _save(manager.canvas.figure)
+    Gcf.destroy_all()
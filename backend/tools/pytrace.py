@@ -0,0 +1,185 @@
+"""Step tracer for the Python visualiser.
+
+Usage: pytrace.py --max-steps N --max-bytes N --output PATH SCRIPT
+
+The traced program reads its stdin from ours. A single JSON document describing
+every executed step is written to PATH when the program finishes. The program
+shares our stdout, so the trace never goes there where it could be forged.
+"""
+
+import argparse
+import builtins
+import io
+import json
+import math
+import sys
+import types
+
+PRIMITIVES = (type(None), bool, int, str)
+
+
+class StopTracing(BaseException):
+    pass
+
+
+class Tracer:
+    def __init__(self, filename, max_steps, max_bytes):
+        self.filename = filename
+        self.max_steps = max_steps
+        self.max_bytes = max_bytes
+        self.steps = []
+        self.size = 0
+        self.truncated = None
+        self.stdout = io.StringIO()
+        self.module_globals = None
+
+    # values are encoded the same way as Python Tutor: primitives inline,
+    # everything else as ["REF", id] pointing into the per-step heap.
+    def encode(self, value, heap):
+        if isinstance(value, PRIMITIVES):
+            if isinstance(value, str) and len(value) > 1000:
+                return value[:1000] + "..."
+            return value
+        if isinstance(value, float):
+            if math.isnan(value) or math.isinf(value):
+                return ["SPECIAL_FLOAT", repr(value)]
+            return value
+
+        ref = id(value)
+        if ref in heap:
+            return ["REF", ref]
+        heap[ref] = None
+
+        if isinstance(value, list):
+            heap[ref] = ["LIST"] + [self.encode(v, heap) for v in value[:100]]
+        elif isinstance(value, tuple):
+            heap[ref] = ["TUPLE"] + [self.encode(v, heap) for v in value[:100]]
+        elif isinstance(value, (set, frozenset)):
+            heap[ref] = ["SET"] + [self.encode(v, heap) for v in list(value)[:100]]
+        elif isinstance(value, dict):
+            items = list(value.items())[:100]
+            heap[ref] = ["DICT"] + [[self.encode(k, heap), self.encode(v, heap)] for k, v in items]
+        elif isinstance(value, (types.FunctionType, types.BuiltinFunctionType, types.MethodType)):
+            heap[ref] = ["FUNCTION", getattr(value, "__qualname__", repr(value))]
+        elif isinstance(value, type):
+            attrs = [[k, self.encode(v, heap)] for k, v in vars(value).items() if not k.startswith("__")]
+            heap[ref] = ["CLASS", value.__name__, [b.__name__ for b in value.__bases__]] + attrs
+        elif isinstance(value, types.ModuleType):
+            heap[ref] = ["MODULE", value.__name__]
+        elif hasattr(value, "__dict__"):
+            attrs = [[k, self.encode(v, heap)] for k, v in vars(value).items()]
+            heap[ref] = ["INSTANCE", type(value).__name__] + attrs
+        else:
+            heap[ref] = ["OTHER", type(value).__name__, repr(value)[:200]]
+        return ["REF", ref]
+
+    def record(self, frame, event, arg):
+        heap = {}
+        stack = []
+        f = frame
+        while f is not None:
+            if f.f_code.co_filename == self.filename and f.f_globals is self.module_globals and f.f_code.co_name != "<module>":
+                local_vars = {k: self.encode(v, heap) for k, v in f.f_locals.items()}
+                if f is frame and event == "return":
+                    local_vars["__return__"] = self.encode(arg, heap)
+                stack.append({
+                    "func_name": f.f_code.co_name,
+                    "line": f.f_lineno,
+                    "frame_id": id(f),
+                    "locals": local_vars,
+                })
+            f = f.f_back
+        stack.reverse()
+
+        global_vars = {}
+        for k, v in self.module_globals.items():
+            if k.startswith("__") and k.endswith("__"):
+                continue
+            global_vars[k] = self.encode(v, heap)
+
+        step = {
+            "event": event,
+            "line": frame.f_lineno,
+            "func_name": frame.f_code.co_name,
+            "stack": stack,
+            "globals": global_vars,
+            "heap": {str(k): v for k, v in heap.items()},
+            "stdout": self.stdout.getvalue(),
+        }
+        if event == "exception":
+            step["exception_msg"] = "%s: %s" % (arg[0].__name__, arg[1])
+
+        encoded = json.dumps(step)
+        if self.size + len(encoded) > self.max_bytes:
+            self.stop("size_limit")
+        self.size += len(encoded)
+        self.steps.append(step)
+        if len(self.steps) >= self.max_steps:
+            self.stop("step_limit")
+
+    def stop(self, reason):
+        self.truncated = reason
+        sys.settrace(None)
+        raise StopTracing()
+
+    def trace(self, frame, event, arg):
+        if self.truncated is not None:
+            return None
+        if frame.f_code.co_filename != self.filename:
+            return None
+        if event in ("call", "line", "return", "exception"):
+            self.record(frame, event, arg)
+        return self.trace
+
+    def run(self, source):
+        result = {"steps": self.steps, "truncated": None, "exception": None, "stdout": ""}
+        try:
+            code = compile(source, self.filename, "exec")
+        except SyntaxError as e:
+            result["exception"] = {"type": "SyntaxError", "message": e.msg, "line": e.lineno}
+            return result
+
+        self.module_globals = {"__name__": "__main__", "__file__": self.filename, "__builtins__": builtins}
+        real_stdout = sys.stdout
+        sys.stdout = self.stdout
+        sys.settrace(self.trace)
+        try:
+            exec(code, self.module_globals)
+        except StopTracing:
+            pass
+        except BaseException as e:
+            tb = e.__traceback__
+            line = None
+            while tb is not None:
+                if tb.tb_frame.f_code.co_filename == self.filename:
+                    line = tb.tb_lineno
+                tb = tb.tb_next
+            result["exception"] = {"type": type(e).__name__, "message": str(e), "line": line}
+        finally:
+            sys.settrace(None)
+            sys.stdout = real_stdout
+
+        result["truncated"] = self.truncated
+        result["stdout"] = self.stdout.getvalue()
+        return result
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--max-steps", type=int, default=1000)
+    parser.add_argument("--max-bytes", type=int, default=4 * 1024 * 1024)
+    parser.add_argument("--output", required=True)
+    parser.add_argument("script")
+    args = parser.parse_args()
+
+    with open(args.script, encoding="utf-8") as f:
+        source = f.read()
+
+    tracer = Tracer(args.script, args.max_steps, args.max_bytes)
+    result = tracer.run(source)
+    with open(args.output, "w", encoding="utf-8") as f:
+        json.dump(result, f)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,105 @@
+"""SQL runner for the SQL language entry.
+
+Usage: sqlrun.py --database DB --results FILE [--setup FILE ...] SCRIPT
+
+Every --setup script that exists (schema, seed, per-test-case data) is run first
+with executescript. SCRIPT is then split into statements and run one by one.
+Results are written to FILE as JSON:
+
+    {"statements": [{"sql": "...", "columns": [...] | null, "rows": [[...]],
+                     "rows_affected": N, "truncated": false, "error": null}]}
+
+Errors are also printed to stderr. Execution continues after a failing
+statement, like the sqlite3 shell does, but the exit status is 1.
+"""
+
+import argparse
+import json
+import os
+import sqlite3
+import sys
+
+MAX_ROWS = 1000
+
+
+def split_statements(script):
+    # a ';' ends a statement only where sqlite agrees, not inside strings,
+    # comments or trigger bodies
+    statements = []
+    current = ""
+    for char in script:
+        current += char
+        if char == ";" and sqlite3.complete_statement(current):
+            statements.append(current.strip())
+            current = ""
+    if current.strip():
+        statements.append(current.strip())
+    return statements
+
+
+def encode(value):
+    if isinstance(value, bytes):
+        return "X'" + value.hex().upper() + "'"
+    if isinstance(value, float) and value != value:
+        return None
+    return value
+
+
+def run_statement(conn, sql):
+    result = {"sql": sql, "columns": None, "rows": [], "rows_affected": 0, "truncated": False, "error": None}
+    try:
+        cursor = conn.execute(sql)
+        if cursor.description is not None:
+            result["columns"] = [column[0] for column in cursor.description]
+            rows = cursor.fetchmany(MAX_ROWS + 1)
+            if len(rows) > MAX_ROWS:
+                rows = rows[:MAX_ROWS]
+                result["truncated"] = True
+            result["rows"] = [[encode(v) for v in row] for row in rows]
+        else:
+            result["rows_affected"] = max(cursor.rowcount, 0)
+    except (sqlite3.Error, sqlite3.Warning) as exc:
+        result["error"] = str(exc)
+    return result
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--database", required=True)
+    parser.add_argument("--results", required=True)
+    parser.add_argument("--setup", action="append", default=[])
+    parser.add_argument("script")
+    args = parser.parse_args()
+
+    # autocommit so that BEGIN/COMMIT in the script behave as written
+    conn = sqlite3.connect(args.database, isolation_level=None)
+
+    for path in args.setup:
+        if not os.path.exists(path):
+            continue
+        with open(path, encoding="utf-8") as f:
+            try:
+                conn.executescript(f.read())
+            except sqlite3.Error as exc:
+                print("setup failed (%s): %s" % (os.path.basename(path), exc), file=sys.stderr)
+                sys.exit(2)
+
+    with open(args.script, encoding="utf-8") as f:
+        script = f.read()
+
+    statements = []
+    failed = False
+    for sql in split_statements(script):
+        result = run_statement(conn, sql)
+        if result["error"] is not None:
+            failed = True
+            print("Error: %s\n  in: %s" % (result["error"], sql.splitlines()[0]), file=sys.stderr)
+        statements.append(result)
+
+    with open(args.results, "w", encoding="utf-8") as f:
+        json.dump({"statements": statements}, f)
+    sys.exit(1 if failed else 0)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// trace 모드는 비대화형이라 stdin 을 code 메시지에서 한 번에 받는다.
+const (
+	traceMaxSteps   = 1000
+	traceMaxBytes   = 4 * 1024 * 1024
+	traceStdinLimit = 64 * 1024
+	// tracer 가 trace 를 쓰는 파일. 프로그램과 stdout 을 같이 쓰므로 trace 는 stdout 으로 받지 않는다.
+	traceOutputFile = "trace.json"
+)
+
+func runTrace(ctx *ConnectionContext, option CompileOption, stdin string) error {
+	if len(stdin) > traceStdinLimit {
+		ctx.write(map[string]interface{}{
+			"type":  "trace_error",
+			"error": fmt.Sprintf("stdin exceeds %d bytes", traceStdinLimit),
+		})
+		return nil
+	}
+
+	// 프로그램도 /box 에 쓸 수 있으므로 이전 trace 를 지우고, 끝난 뒤에는 symlink 를 따라가지 않게 읽는다.
+	boxDir := filepath.Join(isolateBoxRoot, ctx.box.isolateID(), "box")
+	outputPath := filepath.Join(boxDir, traceOutputFile)
+	if err := removeAllBeneath(boxDir, outputPath); err != nil {
+		ctx.write(map[string]interface{}{
+			"type":  "trace_error",
+			"error": fmt.Sprintf("failed to clear trace output: %v", err),
+		})
+		return nil
+	}
+
+	args := ctx.box.isolateArgs()
+	args = append(args, "--silent", "--time=10", "--wall-time=20", "--run", "--")
+	args = append(args, option.TraceCmd...)
+	args = append(args,
+		"--max-steps="+strconv.Itoa(traceMaxSteps),
+		"--max-bytes="+strconv.Itoa(traceMaxBytes),
+		"--output=/box/"+traceOutputFile,
+		option.Filename,
+	)
+
+	stderr := &limitedBuffer{limit: 64 * 1024}
+
+	cmd := exec.Command(isolateBinary, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = stderr
+	runErr := cmd.Run()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	// tracer 가 한도를 넘기면 스스로 멈추므로, 여유분을 둔 한도를 넘으면 비정상 출력으로 본다.
+	trace, readErr := readFileBeneath(boxDir, outputPath, traceMaxBytes+1024*1024)
+	if readErr != nil {
+		log.Println("trace output read error:", readErr)
+	}
+	if readErr != nil || !json.Valid(trace) {
+		ctx.write(map[string]interface{}{
+			"type":   "trace_error",
+			"error":  fmt.Sprintf("tracer did not produce a trace: %v", runErr),
+			"stderr": stderr.String(),
+		})
+	} else {
+		ctx.write(map[string]interface{}{
+			"type":  "trace",
+			"trace": json.RawMessage(trace),
+		})
+	}
+
+	ctx.write(map[string]interface{}{
+		"type":        "exit",
+		"return_code": exitCode,
+		"error":       fmt.Sprintf("%v", runErr),
+	})
+	ctx.finishRun()
+	return nil
+}
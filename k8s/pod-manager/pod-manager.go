@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +30,8 @@ import (
 
 const (
 	TimestampImageTag = "TIMESTAMP_IMAGE_TAG"
+	// 러너는 이 헤더에 자기 토큰이 없는 요청을 거절한다.
+	runnerTokenHeader = "X-Iris-Runner-Token"
 )
 
 type RunnerPod struct {
@@ -29,6 +39,15 @@ type RunnerPod struct {
 	IP         string
 	CreatedAt  time.Time
 	LastUsedAt time.Time
+	// 러너가 /status 로 알려 준 isolate box 수. 한 pod 에 이만큼 세션을 동시에 보낸다.
+	Capacity int
+
+	// 아래는 pm.mu 로 보호한다.
+	sessions int
+	queued   bool
+	retired  bool
+	// 마지막 세션이 끝나고 /reset 으로 정리하는 중이다. 이 동안은 빌려 주지 않는다.
+	resetting bool
 }
 
 type PodManager struct {
@@ -40,14 +59,28 @@ type PodManager struct {
 	targetPoolSize int
 	leaseTimeout   time.Duration
 	readyTimeout   time.Duration
+	// 러너 컨테이너에 그대로 넘기는 선택 기능 설정
+	runnerEnv []corev1.EnvVar
+	// 러너가 pod manager 가 보낸 요청만 받도록 pod 마다 넣어 주는 공유 토큰
+	runnerToken string
 
 	idlePods chan *RunnerPod
 
-	mu           sync.Mutex
-	busyPods     map[string]*RunnerPod
+	mu       sync.Mutex
+	busyPods map[string]*RunnerPod
+	// 지우기로 하지 않은 준비된 pod 전체. 세션을 나눠 받는 pod 은 idlePods 와 busyPods 에 함께 있을 수 있다.
+	pods         map[string]*RunnerPod
 	provisioning int
+	// 러너가 발급한 미리보기 토큰 → 해당 세션의 pod. 세션이 끝나면 지운다.
+	previews map[string]*RunnerPod
+
+	// 모든 pod 이 같은 이미지이므로 capabilities 응답은 잠시 캐시해서 pod 을 빌리는 횟수를 줄인다.
+	capabilities          []byte
+	capabilitiesFetchedAt time.Time
 }
 
+const capabilitiesCacheTTL = time.Minute
+
 func NewPodManager(clientset *kubernetes.Clientset) (*PodManager, error) {
 	imageTag := os.Getenv(TimestampImageTag)
 	if imageTag == "" {
@@ -64,13 +97,37 @@ func NewPodManager(clientset *kubernetes.Clientset) (*PodManager, error) {
 		leaseTimeoutSec = 1
 	}
 
-	readyTimeoutSec := envInt("RUNNER_READY_TIMEOUT_SEC", 90)
+	// 러너는 시작할 때 모든 언어의 self-test 를 마친 뒤에 ready 가 된다.
+	readyTimeoutSec := envInt("RUNNER_READY_TIMEOUT_SEC", 180)
 	if readyTimeoutSec < 10 {
 		readyTimeoutSec = 10
 	}
 
 	namespace := envString("RUNNER_NAMESPACE", "default")
 
+	// Java 시작 시간 최적화. javac 데몬은 pod 메모리를 더 쓰므로 설정하지 않으면 러너에서 꺼져 있다.
+	var runnerEnv []corev1.EnvVar
+	for _, pair := range [][2]string{
+		{"RUNNER_JAVAC_DAEMON", "IRIS_JAVAC_DAEMON"},
+		{"RUNNER_JAVA_CDS", "IRIS_JAVA_CDS"},
+		{"RUNNER_BOX_POOL_SIZE", "IRIS_BOX_POOL_SIZE"},
+	} {
+		if value := envString(pair[0], ""); value != "" {
+			runnerEnv = append(runnerEnv, corev1.EnvVar{Name: pair[1], Value: value})
+		}
+	}
+
+	// 설정하지 않으면 시작할 때마다 새로 만든다. 이전 manager 가 만든 pod 은 어차피 다시 쓰지 않는다.
+	runnerToken := envString("RUNNER_TOKEN", "")
+	if runnerToken == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate runner token: %w", err)
+		}
+		runnerToken = hex.EncodeToString(buf)
+	}
+	runnerEnv = append(runnerEnv, corev1.EnvVar{Name: "IRIS_RUNNER_TOKEN", Value: runnerToken})
+
 	pm := &PodManager{
 		clientset:      clientset,
 		logger:         log.New(os.Stdout, "[Pod Manager] ", log.LstdFlags),
@@ -79,8 +136,12 @@ func NewPodManager(clientset *kubernetes.Clientset) (*PodManager, error) {
 		targetPoolSize: poolSize,
 		leaseTimeout:   time.Duration(leaseTimeoutSec) * time.Second,
 		readyTimeout:   time.Duration(readyTimeoutSec) * time.Second,
+		runnerEnv:      runnerEnv,
+		runnerToken:    runnerToken,
 		idlePods:       make(chan *RunnerPod, poolSize),
 		busyPods:       make(map[string]*RunnerPod),
+		pods:           make(map[string]*RunnerPod),
+		previews:       make(map[string]*RunnerPod),
 	}
 
 	return pm, nil
@@ -119,7 +180,7 @@ func (pm *PodManager) startWarmPool() {
 
 func (pm *PodManager) ensurePool() {
 	pm.mu.Lock()
-	currentTotal := len(pm.idlePods) + len(pm.busyPods) + pm.provisioning
+	currentTotal := len(pm.pods) + pm.provisioning
 	missing := pm.targetPoolSize - currentTotal
 	if missing <= 0 {
 		pm.mu.Unlock()
@@ -146,12 +207,33 @@ func (pm *PodManager) provisionOnePod() {
 		return
 	}
 
+	pm.mu.Lock()
+	queued := pm.queueLocked(pod)
+	if queued {
+		pm.pods[pod.Name] = pod
+	}
+	pm.mu.Unlock()
+
+	if queued {
+		pm.logger.Printf("Warm pod ready: %s (%s, %d boxes)", pod.Name, pod.IP, pod.Capacity)
+		return
+	}
+	pm.logger.Printf("Idle pool is full, deleting extra pod: %s", pod.Name)
+	_ = pm.deleteRunnerPod(pod.Name)
+}
+
+// queueLocked 는 pod 을 빌려 줄 수 있게 idlePods 에 넣는다. 이미 들어 있으면 다시 넣지 않는다.
+// pm.mu 를 잡은 상태에서 호출한다.
+func (pm *PodManager) queueLocked(pod *RunnerPod) bool {
+	if pod.queued {
+		return true
+	}
 	select {
 	case pm.idlePods <- pod:
-		pm.logger.Printf("Warm pod ready: %s (%s)", pod.Name, pod.IP)
+		pod.queued = true
+		return true
 	default:
-		pm.logger.Printf("Idle pool is full, deleting extra pod: %s", pod.Name)
-		_ = pm.deleteRunnerPod(pod.Name)
+		return false
 	}
 }
 
@@ -169,9 +251,42 @@ func (pm *PodManager) createAndWaitRunnerPod() (*RunnerPod, error) {
 	}
 
 	pod.IP = podIP
+	pod.Capacity = pm.fetchPodCapacity(pod)
 	return pod, nil
 }
 
+// fetchPodCapacity 는 러너의 box 수를 묻는다. /status 가 없는 예전 러너는 세션 하나만 받는다.
+func (pm *PodManager) fetchPodCapacity(pod *RunnerPod) int {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := pm.doRunnerRequest(client, http.MethodGet, fmt.Sprintf("http://%s:8000/status", pod.IP), "", nil)
+	if err != nil {
+		pm.logger.Printf("Pod %s status unavailable, assuming 1 box: %v", pod.Name, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Boxes int `json:"boxes"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&status) != nil || status.Boxes < 1 {
+		return 1
+	}
+	return status.Boxes
+}
+
+// doRunnerRequest 는 러너 토큰을 붙여서 러너 pod 에 HTTP 요청을 보낸다.
+func (pm *PodManager) doRunnerRequest(client *http.Client, method string, target string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(runnerTokenHeader, pm.runnerToken)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return client.Do(req)
+}
+
 func (pm *PodManager) createRunnerPod() (*RunnerPod, error) {
 	privileged := true
 
@@ -192,6 +307,7 @@ func (pm *PodManager) createRunnerPod() (*RunnerPod, error) {
 					Ports: []corev1.ContainerPort{
 						{ContainerPort: 8000},
 					},
+					Env:             pm.runnerEnv,
 					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
 					Resources: corev1.ResourceRequirements{
 						Limits: corev1.ResourceList{
@@ -202,10 +318,11 @@ func (pm *PodManager) createRunnerPod() (*RunnerPod, error) {
 							corev1.ResourceMemory: resource.MustParse("512Mi"),
 						},
 					},
+					// readiness 는 isolate 와 언어 도구까지 확인하는 /readyz, liveness 는 가벼운 /healthz 를 본다.
 					ReadinessProbe: &corev1.Probe{
 						ProbeHandler: corev1.ProbeHandler{
 							HTTPGet: &corev1.HTTPGetAction{
-								Path: "/healthz",
+								Path: "/readyz",
 								Port: intstr.FromInt(8000),
 							},
 						},
@@ -314,38 +431,112 @@ func (pm *PodManager) leasePod() (*RunnerPod, error) {
 	timer := time.NewTimer(pm.leaseTimeout)
 	defer timer.Stop()
 
-	select {
-	case pod := <-pm.idlePods:
-		pm.mu.Lock()
-		pm.busyPods[pod.Name] = pod
-		pm.mu.Unlock()
-		return pod, nil
-	case <-timer.C:
-		return nil, errors.New("warm pod pool exhausted")
+	for {
+		select {
+		case pod := <-pm.idlePods:
+			pm.mu.Lock()
+			pod.queued = false
+			if pod.retired || pod.resetting {
+				// 교체하기로 한 pod 이 줄에 남아 있던 것이다. 정리 중인 pod 은 정리가 끝나면 다시 줄에 들어간다.
+				pm.mu.Unlock()
+				continue
+			}
+			pod.sessions++
+			pm.busyPods[pod.Name] = pod
+			// box 가 남아 있으면 다음 세션도 이 pod 을 받도록 다시 줄에 넣는다.
+			if pod.sessions < pod.Capacity {
+				pm.queueLocked(pod)
+			}
+			pm.mu.Unlock()
+			return pod, nil
+		case <-timer.C:
+			return nil, errors.New("warm pod pool exhausted")
+		}
 	}
 }
 
+// releasePod 는 세션 하나를 끝낸다. pod 은 마지막 세션이 끝난 뒤에만 지우고,
+// 그 전에 교체가 필요해지면 새 세션을 보내지 않도록 표시만 한다.
 func (pm *PodManager) releasePod(pod *RunnerPod, forceReplace bool) {
 	pm.mu.Lock()
+	pod.sessions--
+	if forceReplace {
+		pod.retired = true
+	}
+	if pod.sessions > 0 {
+		if !pod.retired {
+			pm.queueLocked(pod)
+		}
+		pm.mu.Unlock()
+		return
+	}
 	delete(pm.busyPods, pod.Name)
+	retired := pod.retired
+	pod.resetting = !retired
 	pm.mu.Unlock()
 
-	if !forceReplace && pm.isPodReusable(pod.Name) {
+	if !retired && pm.isPodReusable(pod.Name) && pm.resetPod(pod) {
+		pm.mu.Lock()
+		pod.resetting = false
 		pod.LastUsedAt = time.Now()
-		select {
-		case pm.idlePods <- pod:
+		queued := pm.queueLocked(pod)
+		pm.mu.Unlock()
+		if queued {
 			return
-		default:
-			pm.logger.Printf("Idle pool channel is full, replacing pod: %s", pod.Name)
 		}
+		pm.logger.Printf("Idle pool channel is full, replacing pod: %s", pod.Name)
 	}
 
+	pm.mu.Lock()
+	pod.resetting = false
+	pod.retired = true
+	delete(pm.pods, pod.Name)
+	pm.mu.Unlock()
+
 	if err := pm.deleteRunnerPod(pod.Name); err != nil {
 		pm.logger.Printf("Failed to delete pod %s: %v", pod.Name, err)
 	}
 	pm.ensurePool()
 }
 
+// resetPod 는 러너에게 box 와 workspace 를 정리하게 하고, 남은 프로세스나 파일이 없고
+// 디스크와 메모리가 충분하다고 알려 올 때만 true 를 돌려준다.
+func (pm *PodManager) resetPod(pod *RunnerPod) bool {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := pm.doRunnerRequest(client, http.MethodPost, fmt.Sprintf("http://%s:8000/reset", pod.IP), "", nil)
+	if err != nil {
+		pm.logger.Printf("Pod %s reset failed: %v", pod.Name, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	var report struct {
+		Clean  bool `json:"clean"`
+		Checks []struct {
+			Name   string `json:"name"`
+			OK     bool   `json:"ok"`
+			Detail string `json:"detail"`
+		} `json:"checks"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		pm.logger.Printf("Pod %s reset failed: unexpected status %s", pod.Name, resp.Status)
+		return false
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		pm.logger.Printf("Pod %s reset failed: %v", pod.Name, err)
+		return false
+	}
+	for _, check := range report.Checks {
+		if !check.OK || check.Detail != "" {
+			pm.logger.Printf("Pod %s reset %s: ok=%t %s", pod.Name, check.Name, check.OK, check.Detail)
+		}
+	}
+	if !report.Clean {
+		pm.logger.Printf("Pod %s is dirty after reset, replacing", pod.Name)
+	}
+	return report.Clean
+}
+
 func (pm *PodManager) isPodReusable(podName string) bool {
 	pod, err := pm.clientset.CoreV1().Pods(pm.namespace).Get(
 		context.Background(),
@@ -384,13 +575,21 @@ func (pm *PodManager) dialPodWebSocket(pod *RunnerPod) (*websocket.Conn, error)
 		WriteBufferSize:  1024,
 	}
 
+	header := http.Header{}
+	header.Set(runnerTokenHeader, pm.runnerToken)
+
 	var lastErr error
 	for i := 0; i < 3; i++ {
-		conn, _, err := dialer.Dial(wsURL, nil)
+		conn, resp, err := dialer.Dial(wsURL, header)
 		if err == nil {
 			return conn, nil
 		}
 		lastErr = err
+		// 러너가 이미 다른 세션으로 가득 찼거나 토큰을 거절한 경우는 다시 시도해도 같다.
+		if resp != nil && (resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusForbidden) {
+			lastErr = fmt.Errorf("%w (%s)", err, resp.Status)
+			break
+		}
 		time.Sleep(500 * time.Millisecond)
 	}
 
@@ -407,7 +606,9 @@ func (pm *PodManager) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	forceReplace := false
+	var previewTokens []string
 	defer func() {
+		pm.removePreviews(previewTokens)
 		pm.releasePod(pod, forceReplace)
 	}()
 
@@ -474,6 +675,10 @@ func (pm *PodManager) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				}
 				return
 			}
+			if token := previewToken(messageType, message); token != "" {
+				pm.addPreview(token, pod)
+				previewTokens = append(previewTokens, token)
+			}
 			if err := clientConn.WriteMessage(messageType, message); err != nil {
 				errorChan <- fmt.Errorf("client write: %w", err)
 				return
@@ -484,6 +689,170 @@ func (pm *PodManager) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	if proxyErr := <-errorChan; proxyErr != nil {
 		pm.logger.Printf("Connection ended with error for pod %s: %v", pod.Name, proxyErr)
 	}
+	// previewTokens 를 쓰는 pod 읽기 goroutine 이 끝난 뒤에 정리한다.
+	<-errorChan
+}
+
+// previewToken 은 러너가 보낸 preview 이벤트에서 토큰을 꺼낸다.
+func previewToken(messageType int, message []byte) string {
+	if messageType != websocket.TextMessage || !bytes.Contains(message, []byte(`"preview"`)) {
+		return ""
+	}
+	var event struct {
+		Type  string `json:"type"`
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(message, &event); err != nil || event.Type != "preview" {
+		return ""
+	}
+	return event.Token
+}
+
+func (pm *PodManager) addPreview(token string, pod *RunnerPod) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.previews[token] = pod
+}
+
+func (pm *PodManager) removePreviews(tokens []string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for _, token := range tokens {
+		delete(pm.previews, token)
+	}
+}
+
+// 미리보기 요청은 토큰을 발급한 세션의 pod 으로 경로를 그대로 전달한다.
+// 토큰 확인은 pod 의 러너도 다시 한다.
+func (pm *PodManager) handlePreview(w http.ResponseWriter, r *http.Request) {
+	token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/preview/"), "/")
+
+	pm.mu.Lock()
+	pod := pm.previews[token]
+	pm.mu.Unlock()
+	if token == "" || pod == nil {
+		http.Error(w, "preview not found", http.StatusNotFound)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: "http", Host: fmt.Sprintf("%s:8000", pod.IP)})
+			pr.SetXForwarded()
+			pr.Out.Header.Set(runnerTokenHeader, pm.runnerToken)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			pm.logger.Printf("Preview request to pod %s failed: %v", pod.Name, err)
+			http.Error(w, "Runner pod is unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// format 요청은 짧은 HTTP 요청이고 러너는 포매터를 세션 box 와 다른 box 에서 실행하므로 pod 을 빌리지 않는다.
+func (pm *PodManager) handleFormat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pod, err := pm.sharedPod()
+	if err != nil {
+		pm.logger.Printf("Rejecting format request: %v", err)
+		w.Header().Set("Retry-After", strconv.Itoa(int(pm.leaseTimeout.Seconds())))
+		http.Error(w, "Runner capacity exhausted, retry later", http.StatusServiceUnavailable)
+		return
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	formatURL := fmt.Sprintf("http://%s:8000/format", pod.IP)
+	resp, err := pm.doRunnerRequest(client, http.MethodPost, formatURL, "application/json", http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		// 다른 세션이 쓰고 있을 수 있는 pod 이므로 교체하지 않는다. 정말 죽었으면 세션 쪽에서 교체한다.
+		pm.logger.Printf("Format request to pod %s failed: %v", pod.Name, err)
+		http.Error(w, "Runner pod is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// sharedPod 는 format, capabilities 처럼 세션이 필요 없는 요청을 보낼 pod 을 고른다.
+// 세션 수와 상관없이 준비된 pod 아무거나 쓰고, 줄에서 꺼내지 않으므로 세션 배정에 영향을 주지 않는다.
+// 준비된 pod 이 없으면 leaseTimeout 동안 생기기를 기다린다.
+func (pm *PodManager) sharedPod() (*RunnerPod, error) {
+	deadline := time.Now().Add(pm.leaseTimeout)
+	for {
+		pm.mu.Lock()
+		for _, pod := range pm.pods {
+			if !pod.retired {
+				pm.mu.Unlock()
+				return pod, nil
+			}
+		}
+		pm.mu.Unlock()
+
+		if time.Now().After(deadline) {
+			return nil, errors.New("no ready runner pod")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (pm *PodManager) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pm.mu.Lock()
+	cached := pm.capabilities
+	fresh := time.Since(pm.capabilitiesFetchedAt) < capabilitiesCacheTTL
+	pm.mu.Unlock()
+
+	if cached == nil || !fresh {
+		body, err := pm.fetchCapabilities()
+		if err != nil {
+			pm.logger.Printf("Capabilities request failed: %v", err)
+			if cached == nil {
+				http.Error(w, "Runner pod is unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		} else {
+			cached = body
+			pm.mu.Lock()
+			pm.capabilities = body
+			pm.capabilitiesFetchedAt = time.Now()
+			pm.mu.Unlock()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(cached)
+}
+
+func (pm *PodManager) fetchCapabilities() ([]byte, error) {
+	pod, err := pm.sharedPod()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := pm.doRunnerRequest(client, http.MethodGet, fmt.Sprintf("http://%s:8000/capabilities", pod.IP), "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("pod %s: %w", pod.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pod %s: unexpected status %s", pod.Name, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 }
 
 func isExpectedClose(err error) bool {
@@ -497,13 +866,17 @@ func isExpectedClose(err error) bool {
 
 func (pm *PodManager) handleHealth(w http.ResponseWriter, _ *http.Request) {
 	pm.mu.Lock()
-	idle := len(pm.idlePods)
+	idle := len(pm.pods) - len(pm.busyPods)
 	busy := len(pm.busyPods)
+	sessions := 0
+	for _, pod := range pm.busyPods {
+		sessions += pod.sessions
+	}
 	provisioning := pm.provisioning
 	pm.mu.Unlock()
 
 	w.WriteHeader(http.StatusOK)
-	_, _ = fmt.Fprintf(w, "ok idle=%d busy=%d provisioning=%d", idle, busy, provisioning)
+	_, _ = fmt.Fprintf(w, "ok idle=%d busy=%d sessions=%d provisioning=%d", idle, busy, sessions, provisioning)
 }
 
 func main() {
@@ -525,6 +898,9 @@ func main() {
 	podManager.startWarmPool()
 
 	http.HandleFunc("/run", podManager.handleWebSocket)
+	http.HandleFunc("/format", podManager.handleFormat)
+	http.HandleFunc("/preview/", podManager.handlePreview)
+	http.HandleFunc("/capabilities", podManager.handleCapabilities)
 	http.HandleFunc("/healthz", podManager.handleHealth)
 
 	addr := ":8080"